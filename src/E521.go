@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/rand"
+	"errors"
 	"math/big"
 )
 
@@ -8,14 +10,13 @@ import (
  * E521 Elliptic Curve (Edward's Curve) of equation: (x^2) + (y^2) = 1 + d(x^2)(y^2)
  * where d = -376014
  * Contains methods to add and multiply points on curve using scalar values.
+ * Coordinates are held as field elements, see field521.go, and converted to
+ * big.Int only at encodings and the API boundary. The curve constants p, d
+ * and r are shared by all points.
  */
 type E521 struct {
-	x big.Int //X coordinate
-	y big.Int // Y cooridinate
-	p big.Int // Mersenne prime defining a finite field F(p)
-	d big.Int // d = -376014
-	r big.Int // number of points on Curve -> n := 4 * (R) .
-	n big.Int //4 * r
+	x fieldElement // X coordinate
+	y fieldElement // Y coordinate
 }
 
 // number of points on Curve -> n := 4 * (R) .
@@ -33,126 +34,204 @@ func (e *E521) getP() big.Int {
 	return *P
 }
 
-// constructor for E521 for any x, y
+// constructor for E521 for any x, y, reduced mod p
 func NewE521XY(x, y big.Int) *E521 {
-	tempR := new(E521).getR()
-	point := E521{
-		x: x,
-		y: y,
-		p: new(E521).getP(),
-		d: *big.NewInt(-376014),
-		r: tempR,
-		n: *new(E521).r.Mul(&tempR, big.NewInt(4)),
-	}
-	return &point
+	return &E521{x: *feFromBig(&x), y: *feFromBig(&y)}
 }
 
 // constructor for E521, solves for y
 func NewE521X(x big.Int, msb uint) *E521 {
-	tempR := new(E521).getR()
-	point := E521{
-		x: x,
-		y: *solveForY(&x, new(E521).getP(), msb),
-		p: new(E521).getP(),
-		d: *big.NewInt(-376014),
-		r: tempR,
-		n: *new(E521).r.Mul(&tempR, big.NewInt(4)),
-	}
-	return &point
+	fx := feFromBig(&x)
+	y, _ := solveForY(fx, msb)
+	return &E521{x: *fx, y: *y}
 }
 
-// Generator point for the curve, with x = 4 and y a unique even number obtained
-// from solving curve equation.
-func E521GenPoint(msb uint) *E521 {
-	tempR := new(E521).getR()
-
+/*
+Constructs a point from coordinates read from untrusted input, such as a
+legacy key file or cryptogram. Unlike NewE521XY it does not reduce them:
+coordinates outside [0, p) and points off the curve are rejected with an
+error wrapping ErrInvalidPoint.
+*/
+func newE521Checked(x, y *big.Int) (*E521, error) {
 	P := new(E521).getP()
-	X := big.NewInt(4)
-	Y := solveForY(X, P, msb)
-	point := E521{
-		x: *X,
-		y: *Y,
-		p: P,
-		d: *big.NewInt(-376014),
-		r: tempR,
-		n: *new(E521).r.Mul(&tempR, big.NewInt(4)),
+	if x.Sign() < 0 || x.Cmp(&P) >= 0 || y.Sign() < 0 || y.Cmp(&P) >= 0 {
+		return nil, wrapErr(ErrInvalidPoint, errors.New("coordinate out of range"))
 	}
-	return &point
+	point := NewE521XY(*x, *y)
+	if !point.IsOnCurve() {
+		return nil, wrapErr(ErrInvalidPoint, errors.New("point is not on E521"))
+	}
+	return point, nil
+}
 
+// Generator point for the curve, with x = 4 and y a unique even number obtained
+// from solving curve equation. The point is computed once and copied on each call.
+func E521GenPoint(msb uint) *E521 {
+	G := *cachedGenPoint(msb)
+	return &G
 }
 
-// solves curve equation for y value
-func solveForY(X *big.Int, P big.Int, msb uint) *big.Int {
-	num := new(big.Int).Sub(big.NewInt(1), new(big.Int).Exp(X, big.NewInt(2), nil))
-	// fmt.Println("num: ", num)
-	num = num.Mod(num, &P)
-	// fmt.Println("num mod p: ", num)
-	denom := new(big.Int).Add(big.NewInt(1), (new(big.Int).Mul(big.NewInt(376014), new(big.Int).Exp(X, big.NewInt(2), nil))))
-	// fmt.Println("denom: ", denom)
-	denom = denom.Mod(denom, &P)
-	// fmt.Println("denom mod p: ", denom)
-	denom = new(big.Int).ModInverse(denom, &P)
-	// fmt.Println("denom mod inv: ", denom)
-	radicand := new(big.Int).Mul(num, denom)
-	// fmt.Println("radicand: ", radicand)
-	Y := sqrt(radicand, msb)
-	// fmt.Println("y: ", Y)
-	return Y
+/*
+Solves the curve equation for y: y^2 = (1 - x^2) / (1 - d x^2). Returns the
+root whose least significant bit is msb and 1, or an unspecified element
+and 0 if x is not the x coordinate of any point.
+*/
+func solveForY(x *fieldElement, msb uint) (*fieldElement, int) {
+	x2 := new(fieldElement).Square(x)
+	num := new(fieldElement).Sub(feFromUint64(1), x2)
+	denom := new(fieldElement).Mul(&e521D, x2)
+	denom.Sub(feFromUint64(1), denom)
+	radicand := num.Mul(num, denom.Invert(denom))
+	return sqrt(radicand, msb)
 }
 
 // The identity point of the curve (also refered to as "point at infinity").
 // Equivalent to 0 in integer group.
-func E521IdPoint() *E521 { return NewE521XY(*big.NewInt(0), *big.NewInt(1)) }
+func E521IdPoint() *E521 { return &E521{y: *feFromUint64(1)} }
 
 /*
 Gets the opposite value of a point, defined as the following:
-if P = (X, Y), opposite of P = (-X, Y).
+if P = (X, Y), opposite of P = (-X, Y). Leaves e unchanged.
 */
-func (e *E521) getOpposite() *E521 { return NewE521XY(*e.x.Neg(&e.x), e.y) }
+func (e *E521) getOpposite() *E521 {
+	return &E521{x: *new(fieldElement).Neg(&e.x), y: e.y}
+}
 
-// Checks two points for equality by comparing their coordinates.
-func (A *E521) Equals(B *E521) bool { return A.x.Cmp(&B.x) == 0 && A.y.Cmp(&B.y) == 0 }
+// Checks that the coordinates satisfy the curve equation
+// x^2 + y^2 = 1 + d(x^2)(y^2) mod p.
+func (e *E521) IsOnCurve() bool {
+	x2 := new(fieldElement).Square(&e.x)
+	y2 := new(fieldElement).Square(&e.y)
+	lhs := new(fieldElement).Add(x2, y2)
+	rhs := new(fieldElement).Mul(x2, y2)
+	rhs.Add(rhs.Mul(rhs, &e521D), feFromUint64(1))
+	return lhs.Equal(rhs) == 1
+}
 
-/*
-Adds two E521 points and returns another E521 curve point.
-Point addition operation is defined as:
+// Checks whether this is the neutral element (0, 1).
+func (e *E521) IsIdentity() bool {
+	return e.x.IsZero() == 1 && e.y.Equal(feFromUint64(1)) == 1
+}
 
-	(x1, y1) + (x2, y2) = ((x1y2 + y1x2) / (1 + (d)x1x2y1y2)), ((y1y2 - x1x2) / (1 - (d)x1x2y1y2))
+// Checks whether the point has order dividing r, i.e. r*P = O. Points
+// outside this subgroup carry a small-order component of order 2 or 4.
+// Variable time; the point is public.
+func (e *E521) IsInPrimeSubgroup() bool {
+	R := e.getR()
+	return MultiScalarMul([]*E521{e}, []*big.Int{&R}).IsIdentity()
+}
 
-where "/" is defined to be multiplication by modular inverse.
+/*
+Validates a point decoded from untrusted input before it is used with a
+secret scalar. Rejects points off the curve (invalid-curve attacks), the
+identity, and points outside the prime order subgroup (small-subgroup
+attacks).
+
+	return: nil, or an error wrapping ErrInvalidPoint
 */
-func (A *E521) Add(B *E521) *E521 {
+func (e *E521) validate() error {
+	switch {
+	case !e.IsOnCurve():
+		return wrapErr(ErrInvalidPoint, errors.New("point is not on E521"))
+	case e.IsIdentity():
+		return wrapErr(ErrInvalidPoint, errors.New("point is the identity"))
+	case !e.IsInPrimeSubgroup():
+		return wrapErr(ErrInvalidPoint, errors.New("point is not in the prime order subgroup"))
+	}
+	return nil
+}
 
-	x1, y1, x2, y2 := A.x, A.y, B.x, B.y
+// Length in bytes of a compressed point: x big-endian with the lsb of y in the top bit.
+const E521CompressedSize = 66
 
-	xNum := new(big.Int).Add(new(big.Int).Mul(&x1, &y2), new(big.Int).Mul(&y1, &x2))
-	xNum.Mod(xNum, &A.p)
+// Length in bytes of an uncompressed point: x || y, each big-endian.
+const E521UncompressedSize = 2 * E521CompressedSize
 
-	mul := new(big.Int).Mul(&A.d, &x1) //x1 * x2 *  y1 * y2
-	mul = new(big.Int).Mul(mul, &x2)
-	mul = new(big.Int).Mul(mul, &y1)
-	mul = new(big.Int).Mul(mul, &y2)
+// Returns the coordinates of the point as integers in [0, p), for the API boundary.
+func (e *E521) affine() (*big.Int, *big.Int) {
+	return e.x.BigInt(), e.y.BigInt()
+}
 
-	xDenom := new(big.Int).Add(big.NewInt(1), mul)
-	xDenom.Mod(xDenom, &A.p)
-	xDenom = new(big.Int).ModInverse(xDenom, &A.p)
+/*
+Implements encoding.BinaryMarshaler with the canonical 66 byte compressed
+encoding. x occupies the low 521 bits in big-endian order and the most
+significant bit of the first byte holds the least significant bit of y.
+*/
+func (e *E521) MarshalBinary() ([]byte, error) {
+	out := e.x.Bytes()
+	out[0] |= byte(e.y.lsb()) << 7
+	return out, nil
+}
 
-	newX := new(big.Int).Mul(xNum, xDenom)
-	newX.Mod(newX, &A.p)
+// Returns the 132 byte uncompressed encoding x || y.
+func (e *E521) MarshalUncompressed() []byte {
+	return append(e.x.Bytes(), e.y.Bytes()...)
+}
 
-	yNum := new(big.Int).Sub(new(big.Int).Mul(&y1, &y2), new(big.Int).Mul(&x1, &x2))
-	yNum.Mod(yNum, &A.p)
+/*
+Implements encoding.BinaryUnmarshaler for both the compressed and the
+uncompressed encoding, distinguished by length. Non-canonical inputs are
+rejected: coordinates must be less than p, unused bits must be zero, and
+the decoded point must lie on the curve. The point is not checked for
+membership in the prime order subgroup; see validate.
+*/
+func (e *E521) UnmarshalBinary(data []byte) error {
+	var point E521
+	switch len(data) {
+	case E521CompressedSize:
+		if data[0]&0x7E != 0 {
+			return wrapErr(ErrInvalidPoint, errors.New("non-canonical compressed point"))
+		}
+		lsb := uint(data[0] >> 7)
+		xBytes := append([]byte{data[0] & 0x01}, data[1:]...)
+		if _, ok := point.x.SetBytes(xBytes); ok != 1 {
+			return wrapErr(ErrInvalidPoint, errors.New("x coordinate out of range"))
+		}
+		y, ok := solveForY(&point.x, lsb)
+		if ok != 1 || uint(y.lsb()) != lsb {
+			return wrapErr(ErrInvalidPoint, errors.New("no point with given x and sign"))
+		}
+		point.y = *y
+	case E521UncompressedSize:
+		_, okX := point.x.SetBytes(data[:E521CompressedSize])
+		_, okY := point.y.SetBytes(data[E521CompressedSize:])
+		if okX&okY != 1 {
+			return wrapErr(ErrInvalidPoint, errors.New("coordinate out of range"))
+		}
+	default:
+		return wrapErr(ErrMalformed, errors.New("invalid point encoding length"))
+	}
+	if !point.IsOnCurve() {
+		return wrapErr(ErrInvalidPoint, errors.New("point is not on E521"))
+	}
+	*e = point
+	return nil
+}
 
-	yDenom := new(big.Int).Sub(big.NewInt(1), mul)
-	yDenom.Mod(yDenom, &A.p)
-	yDenom = new(big.Int).ModInverse(yDenom, &A.p)
+// Checks two points for equality by comparing their coordinates.
+func (A *E521) Equals(B *E521) bool { return A.x.Equal(&B.x)&A.y.Equal(&B.y) == 1 }
 
-	newY := new(big.Int).Mul(yNum, yDenom)
-	newY.Mod(newY, &A.p)
+/*
+Adds two E521 points and returns another E521 curve point.
+Point addition operation is defined as:
+
+	(x1, y1) + (x2, y2) = ((x1y2 + y1x2) / (1 + (d)x1x2y1y2)), ((y1y2 - x1x2) / (1 - (d)x1x2y1y2))
 
-	return NewE521XY(*newX, *newY)
+where "/" is defined to be multiplication by modular inverse. The sum is
+computed in extended coordinates, see E521Extended.go, so that only a single
+inversion is required.
+*/
+func (A *E521) Add(B *E521) *E521 {
+	return A.toExtended().add(B.toExtended()).toAffine()
 }
 
+// Bits of randomness in the multiple of the curve order added to each scalar.
+const scalarBlindBits = 64
+
+// Number of ladder iterations: s mod n is below 2^521 and the blinded scalar
+// s + m*n with m < 2^64 stays below 2^585.
+const ladderBits = 521 + scalarBlindBits
+
 /*
 EC Multiplication algorithm using the Montgomery Ladder approach to mitigate
 power consumption side channel attacks. Mostly constructed around:
@@ -160,20 +239,61 @@ power consumption side channel attacks. Mostly constructed around:
 (pg 4.)	https://eprint.iacr.org/2014/140.pdf
 
 S is a  scalar value to multiply by. S is a private key and should be kept secret.
+The ladder runs in extended coordinates and converts to affine once at the end.
 Returns Curve.E521 point which is result of multiplication.
+
+Every call blinds the scalar as s + m*n for a random 64 bit m and the curve
+order n = 4r, which leaves the result unchanged for any point on the curve,
+and rescales the starting coordinates by random field elements. The ladder
+always runs ladderBits iterations and selects operands with conditional
+swaps rather than branches.
 */
 func (r1 *E521) SecMul(S *big.Int) *E521 {
-	r0 := NewE521XY(*big.NewInt(0), *big.NewInt(1))
-	for i := S.BitLen(); i >= 0; i-- {
-		if S.Bit(i) == 1 {
-			r0 = r0.Add(r1)
-			r1 = r1.Add(r1)
-		} else {
-			r1 = r0.Add(r1)
-			r0 = r0.Add(r0)
-		}
+	R0, R1 := e521ExtIdentity(), r1.toExtended()
+	R0.scale(randomFieldElement())
+	R1.scale(randomFieldElement())
+
+	n := r1.getR()
+	k := blindScalar(S, n.Lsh(&n, 2), ladderBits)
+	prev := 0
+	for i := ladderBits - 1; i >= 0; i-- {
+		bit := int(k[len(k)-1-i/8]>>(i%8)) & 1
+		R0.cswap(R1, bit^prev)
+		prev = bit
+		R1 = R0.add(R1)
+		R0 = R0.double()
+	}
+	R0.cswap(R1, prev)
+	zeroize(k)
+	return R0.toAffine() // R0 = P * s
+}
+
+/*
+Returns s + m*n as a big-endian byte string long enough for bits bits, where
+s is S reduced mod n and m is a fresh random scalarBlindBits bit integer.
+*/
+func blindScalar(S, n *big.Int, bits int) []byte {
+	mBytes := make([]byte, scalarBlindBits/8)
+	if _, err := rand.Read(mBytes); err != nil {
+		panic("unable to read randomness for scalar blinding: " + err.Error())
 	}
-	return r0 // r0 = P * s
+	k := new(big.Int).SetBytes(mBytes)
+	k.Mul(k, n)
+	k.Add(k, new(big.Int).Mod(S, n))
+	out := make([]byte, (bits+7)/8)
+	k.FillBytes(out)
+	k.SetInt64(0)
+	return out
+}
+
+// Returns a uniformly random nonzero field element.
+func randomFieldElement() *fieldElement {
+	buf := make([]byte, feBytes+16)
+	if _, err := rand.Read(buf); err != nil {
+		panic("E521: unable to read randomness for coordinate blinding: " + err.Error())
+	}
+	e := new(fieldElement).SetBigInt(new(big.Int).SetBytes(buf))
+	return e.Select(feFromUint64(1), e, e.IsZero())
 }
 
 /*
@@ -183,27 +303,12 @@ func (r1 *E521) SecMul(S *big.Int) *E521 {
  * @param v   the radicand.
  * lsb is desired least significant bit (true: 1, false: 0).
  * return a square root r of v mod p with r mod 2 = 1 iff lsb = true
- * if such a root exists, otherwise null.
+ * and 1 if such a root exists, otherwise an unspecified element and 0.
+ * The root is computed in constant time by fieldElement.Sqrt.
  */
-func sqrt(v *big.Int, lsb uint) *big.Int {
-
-	if v.Sign() == 0 {
-		return big.NewInt(0)
-	}
-	P := new(E521).getP()
-	r := new(big.Int).Exp(v, new(big.Int).Add(new(big.Int).Rsh(&P, 2), big.NewInt(1)), &P)
-	// fmt.Println("r value: ", r)
-	if r.Bit(0) != lsb {
-		r.Sub(&P, r) // correct the lsb }
-		// fmt.Println("r sub value: ", r)
-		bi := new(big.Int).Sub(new(big.Int).Mul(r, r), v)
-		bi = bi.Mod(bi, &P)
-		// fmt.Println("bi value: ", bi)
-		if bi.Sign() == 0 {
-			return r
-		} else {
-			return nil
-		}
-	}
-	return r
+func sqrt(v *fieldElement, lsb uint) (*fieldElement, int) {
+	r, ok := new(fieldElement).Sqrt(v)
+	neg := new(fieldElement).Neg(r)
+	r.Select(neg, r, r.lsb()^int(lsb&1))
+	return r, ok
 }
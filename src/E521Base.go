@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/subtle"
+	"math/big"
+	"sync"
+)
+
+/*
+Fixed-base multiplication by the generator G = (4, y) with y even.
+
+The generator itself is computed once. A table of 4 bit windows,
+table[i][j] = j * 16^i * G for j in [0, 16), is built on first use, after
+which k*G costs one table lookup and one addition per window and no
+doublings. Lookups read every entry of a row and select the wanted one
+with masks, so the memory access pattern does not depend on the scalar.
+*/
+
+const (
+	genWindowBits = 4
+	genWindowSize = 1 << genWindowBits
+	// the blinded scalar s + m*r fits in the ladder's fixed length
+	genWindows = (ladderBits + genWindowBits - 1) / genWindowBits
+)
+
+var (
+	genOnce   [2]sync.Once
+	genPoints [2]*E521
+
+	genTableOnce sync.Once
+	genTable     *[genWindows][genWindowSize]e521Ext
+)
+
+// Returns the cached generator with the given lsb of y.
+func cachedGenPoint(msb uint) *E521 {
+	msb &= 1
+	genOnce[msb].Do(func() {
+		x := feFromUint64(4)
+		y, _ := solveForY(x, msb)
+		genPoints[msb] = &E521{x: *x, y: *y}
+	})
+	return genPoints[msb]
+}
+
+// Builds the window table for G = E521GenPoint(0).
+func buildGenTable() {
+	table := new([genWindows][genWindowSize]e521Ext)
+	base := E521GenPoint(0).toExtended()
+	for i := range table {
+		table[i][0] = *e521ExtIdentity()
+		for j := 1; j < genWindowSize; j++ {
+			table[i][j] = *table[i][j-1].add(base)
+		}
+		// next base: 16^(i+1) G = 15 * 16^i G + 16^i G
+		base = table[i][genWindowSize-1].add(base)
+	}
+	genTable = table
+}
+
+// Sets p to row[idx], reading every entry of the row.
+func (p *e521Ext) lookup(row *[genWindowSize]e521Ext, idx int) *e521Ext {
+	*p = row[0]
+	for j := 1; j < genWindowSize; j++ {
+		cond := subtle.ConstantTimeEq(int32(j), int32(idx))
+		p.X.Select(&row[j].X, &p.X, cond)
+		p.Y.Select(&row[j].Y, &p.Y, cond)
+		p.Z.Select(&row[j].Z, &p.Z, cond)
+		p.T.Select(&row[j].T, &p.T, cond)
+	}
+	return p
+}
+
+/*
+Multiplies the generator E521GenPoint(0) by the secret scalar S using the
+precomputed table. Equivalent to E521GenPoint(0).SecMul(S), with the same
+scalar blinding and coordinate randomization, but several times faster.
+*/
+func E521GenMul(S *big.Int) *E521 {
+	genTableOnce.Do(buildGenTable)
+	acc := e521ExtIdentity().scale(randomFieldElement())
+	R := new(E521).getR()
+	k := blindScalar(S, &R, ladderBits)
+	var entry e521Ext
+	for i := 0; i < genWindows; i++ {
+		digit := int(k[len(k)-1-i/2]>>(genWindowBits*(i%2))) & (genWindowSize - 1)
+		acc = acc.add(entry.lookup(&genTable[i], digit))
+	}
+	zeroize(k)
+	return acc.toAffine()
+}
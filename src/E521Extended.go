@@ -0,0 +1,118 @@
+package main
+
+import (
+	"math/big"
+)
+
+/*
+E521 point arithmetic in extended twisted Edwards coordinates (X:Y:Z:T) with
+x = X/Z, y = Y/Z and x*y = T/Z. Addition and doubling are inversion free;
+a single field inversion is needed to convert back to affine form. All
+coordinates are constant-time field elements, see field521.go.
+
+	Hisil, Wong, Carter, Dawson: https://eprint.iacr.org/2008/522.pdf
+	add-2008-hwcd and dbl-2008-hwcd with a = 1
+*/
+type e521Ext struct {
+	X, Y, Z, T fieldElement
+}
+
+// Curve constants shared by all extended coordinate operations.
+var (
+	e521P = new(E521).getP()
+	e521D = *new(fieldElement).Neg(feFromUint64(376014))
+)
+
+// The identity point (0 : 1 : 1 : 0).
+func e521ExtIdentity() *e521Ext {
+	return &e521Ext{Y: *feFromUint64(1), Z: *feFromUint64(1)}
+}
+
+// Lifts an affine point to extended coordinates with Z = 1.
+func (e *E521) toExtended() *e521Ext {
+	out := &e521Ext{X: e.x, Y: e.y, Z: *feFromUint64(1)}
+	out.T.Mul(&out.X, &out.Y)
+	return out
+}
+
+// Converts back to affine coordinates using a single inversion of Z.
+func (e *e521Ext) toAffine() *E521 {
+	zInv := new(fieldElement).Invert(&e.Z)
+	out := &E521{}
+	out.x.Mul(&e.X, zInv)
+	out.y.Mul(&e.Y, zInv)
+	return out
+}
+
+/*
+Unified addition, valid for all inputs including doubling and the identity
+since d is not a square mod p:
+
+	A = X1X2, B = Y1Y2, C = dT1T2, D = Z1Z2, E = (X1+Y1)(X2+Y2) - A - B
+	F = D - C, G = D + C, H = B - A
+	X3 = EF, Y3 = GH, T3 = EH, Z3 = FG
+*/
+func (p *e521Ext) add(q *e521Ext) *e521Ext {
+	var A, B, C, D, E, F, G, H, t fieldElement
+	A.Mul(&p.X, &q.X)
+	B.Mul(&p.Y, &q.Y)
+	C.Mul(C.Mul(&e521D, &p.T), &q.T)
+	D.Mul(&p.Z, &q.Z)
+	E.Mul(E.Add(&p.X, &p.Y), t.Add(&q.X, &q.Y))
+	E.Sub(E.Sub(&E, &A), &B)
+	F.Sub(&D, &C)
+	G.Add(&D, &C)
+	H.Sub(&B, &A)
+	return fromEFGH(&E, &F, &G, &H)
+}
+
+/*
+Dedicated doubling, cheaper than add as it needs no multiplication by d:
+
+	A = X^2, B = Y^2, C = 2Z^2, E = (X+Y)^2 - A - B
+	G = A + B, F = G - C, H = A - B
+	X3 = EF, Y3 = GH, T3 = EH, Z3 = FG
+*/
+func (p *e521Ext) double() *e521Ext {
+	var A, B, C, E, F, G, H fieldElement
+	A.Square(&p.X)
+	B.Square(&p.Y)
+	C.Square(&p.Z)
+	C.Add(&C, &C)
+	E.Square(E.Add(&p.X, &p.Y))
+	E.Sub(E.Sub(&E, &A), &B)
+	G.Add(&A, &B)
+	F.Sub(&G, &C)
+	H.Sub(&A, &B)
+	return fromEFGH(&E, &F, &G, &H)
+}
+
+// Final step shared by add and double.
+func fromEFGH(E, F, G, H *fieldElement) *e521Ext {
+	out := &e521Ext{}
+	out.X.Mul(E, F)
+	out.Y.Mul(G, H)
+	out.T.Mul(E, H)
+	out.Z.Mul(F, G)
+	return out
+}
+
+// Returns x mod p as a field element.
+func feFromBig(x *big.Int) *fieldElement { return new(fieldElement).SetBigInt(x) }
+
+// Swaps p and q if cond == 1 and leaves them unchanged if cond == 0, without branching.
+func (p *e521Ext) cswap(q *e521Ext, cond int) {
+	feSwap(&p.X, &q.X, cond)
+	feSwap(&p.Y, &q.Y, cond)
+	feSwap(&p.Z, &q.Z, cond)
+	feSwap(&p.T, &q.T, cond)
+}
+
+// Rescales all coordinates by a nonzero lambda. The point it represents is unchanged.
+func (p *e521Ext) scale(lambda *fieldElement) *e521Ext {
+	p.X.Mul(&p.X, lambda)
+	p.Y.Mul(&p.Y, lambda)
+	p.Z.Mul(&p.Z, lambda)
+	p.T.Mul(&p.T, lambda)
+	return p
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+)
+
+/*
+Hashing to E521 in the manner of RFC 9380, with the suite
+
+	E521_XOF:cSHAKE256_ELL2_RO_   (HashToCurve)
+	E521_XOF:cSHAKE256_ELL2_NU_   (EncodeToCurve)
+
+Messages are expanded with expand_message_xof over cSHAKE256, hashed to
+GF(p) with L = 98 bytes per element (521 bits plus 256 bits of security,
+rounded up), mapped to the Montgomery curve K*t^2 = s^3 + J*s^2 + s that
+is birationally equivalent to E521 with Elligator 2 (Z = -1), carried over
+by the rational map of RFC 9380 appendix D.1, and multiplied by the
+cofactor 4. The resulting points have no known discrete logarithm with
+respect to the generator or to each other.
+
+The field arithmetic is constant time, so secret inputs such as passwords
+may be hashed.
+
+	https://www.rfc-editor.org/rfc/rfc9380
+*/
+
+const (
+	h2cFieldBytes = 98  // L = ceil((521 + 256) / 8)
+	h2cMaxDST     = 255 // longer tags are hashed first, RFC 9380 section 5.3.3
+)
+
+// Customization string given to cSHAKE256 by expandMessageXOF.
+const h2cCustomization = "H2C"
+
+/*
+Montgomery constants of E521 in the reduced form used by Elligator 2, with
+J = 2(1+d)/(1-d) and K = 4/(1-d):
+
+	h2cA = J/K = (1+d)/2, h2cB = 1/K^2, h2cK = K
+*/
+var h2cA, h2cB, h2cK fieldElement
+
+func init() {
+	oneMinusD := feFromUint64(376015)
+	onePlusD := new(fieldElement).Neg(feFromUint64(376013))
+	h2cA.Mul(onePlusD, new(fieldElement).Invert(feFromUint64(2)))
+	h2cK.Mul(feFromUint64(4), new(fieldElement).Invert(oneMinusD))
+	h2cB.Square(new(fieldElement).Invert(&h2cK))
+}
+
+/*
+RFC 9380 section 5.3.2 expand_message_xof with cSHAKE256, returning
+length pseudorandom bytes bound to msg and the domain separation tag dst.
+
+	msg' = msg || I2OSP(length, 2) || dst || I2OSP(len(dst), 1)
+	return: cSHAKE256(msg', 8*length, "", "H2C")
+*/
+func expandMessageXOF(msg, dst []byte, length int) ([]byte, error) {
+	if len(dst) == 0 {
+		return nil, wrapErr(ErrMalformed, errors.New("empty domain separation tag"))
+	}
+	if length <= 0 || length > 65535 {
+		return nil, wrapErr(ErrMalformed, errors.New("requested expansion length out of range"))
+	}
+	if len(dst) > h2cMaxDST {
+		long := append([]byte("H2C-OVERSIZE-DST-"), dst...)
+		dst = cSHAKE256(&long, 512, "", h2cCustomization)
+	}
+	prime := make([]byte, 0, len(msg)+3+len(dst))
+	prime = append(prime, msg...)
+	prime = append(prime, byte(length>>8), byte(length))
+	prime = append(prime, dst...)
+	prime = append(prime, byte(len(dst)))
+	return cSHAKE256(&prime, 8*length, "", h2cCustomization), nil
+}
+
+// RFC 9380 section 5.2 hash_to_field, returning count uniform elements of GF(p).
+func hashToField(msg, dst []byte, count int) ([]fieldElement, error) {
+	uniform, err := expandMessageXOF(msg, dst, count*h2cFieldBytes)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroize(uniform)
+	out := make([]fieldElement, count)
+	for i := range out {
+		feFromWideBytes(&out[i], uniform[i*h2cFieldBytes:(i+1)*h2cFieldBytes])
+	}
+	return out, nil
+}
+
+/*
+Sets e to the big-endian integer b modulo p, for len(b) up to 130 bytes.
+Bits at and above position 521 are folded back down, as 2^521 = 1 (mod p).
+*/
+func feFromWideBytes(e *fieldElement, b []byte) *fieldElement {
+	var lo, hi fieldElement
+	for i := 0; i < 8*len(b); i++ {
+		bit := uint64(b[len(b)-1-i/8]>>(i%8)) & 1
+		if i < feBits {
+			lo[i/58] |= bit << (i % 58)
+		} else {
+			j := i - feBits
+			hi[j/58] |= bit << (j % 58)
+		}
+	}
+	return e.Add(&lo, &hi).reduce()
+}
+
+/*
+Maps a field element to a point of E521, not necessarily in the prime order
+subgroup. Elligator 2 (RFC 9380 section 6.7.1) yields (x, y) on
+y^2 = x^3 + A x^2 + B x, scaled to s = xK, t = yK on the Montgomery curve,
+and E521 coordinates are v = s/t, w = (s-1)/(s+1), with the exceptional
+cases t = 0 and s = -1 sent to the identity.
+*/
+func mapToCurveElligator2(u *fieldElement) *e521Ext {
+	one := feFromUint64(1)
+	var tv1, x1, x2, gx1, gx2, t fieldElement
+
+	// tv1 = Z u^2 with Z = -1, and 0 when 1 + Z u^2 = 0
+	tv1.Neg(t.Square(u))
+	e1 := new(fieldElement).Neg(one).Equal(&tv1)
+	tv1.Select(&fieldElement{}, &tv1, e1)
+
+	// x1 = -A / (1 + tv1), x2 = -x1 - A
+	x1.Invert(t.Add(&tv1, one))
+	x1.Neg(x1.Mul(&x1, &h2cA))
+	x2.Neg(x2.Add(&x1, &h2cA))
+
+	// gx1 = x1^3 + A x1^2 + B x1, and gx2 = Z u^2 gx1
+	gx1.Add(&x1, &h2cA)
+	gx1.Mul(&gx1, &x1)
+	gx1.Add(&gx1, &h2cB)
+	gx1.Mul(&gx1, &x1)
+	gx2.Mul(&tv1, &gx1)
+
+	var x, y2, y, negY fieldElement
+	_, e2 := new(fieldElement).Sqrt(&gx1)
+	x.Select(&x1, &x2, e2)
+	y2.Select(&gx1, &gx2, e2)
+	y.Sqrt(&y2)
+	// sgn0(y) = 1 for the x1 branch and 0 for the x2 branch
+	negY.Neg(&y)
+	y.Select(&negY, &y, e2^y.lsb())
+
+	var s, tt, sp1, sm1, den, inv fieldElement
+	s.Mul(&x, &h2cK)
+	tt.Mul(&y, &h2cK)
+	sp1.Add(&s, one)
+	sm1.Sub(&s, one)
+	den.Mul(&tt, &sp1)
+	exceptional := den.IsZero()
+	inv.Invert(&den)
+
+	out := &e521Ext{Z: *feFromUint64(1)}
+	out.X.Mul(out.X.Mul(&s, &sp1), &inv)
+	out.Y.Mul(out.Y.Mul(&sm1, &tt), &inv)
+	out.Y.Select(one, &out.Y, exceptional)
+	out.T.Mul(&out.X, &out.Y)
+	return out
+}
+
+// Multiplies by the cofactor 4, mapping any curve point into the prime order subgroup.
+func (p *e521Ext) clearCofactor() *e521Ext { return p.double().double() }
+
+/*
+Hashes msg to a uniformly distributed point of the prime order subgroup of
+E521, as hash_to_curve in RFC 9380 section 3. dst is the application's
+domain separation tag and must not be empty. Distinct tags give
+independent hash functions.
+*/
+func HashToCurve(msg, dst []byte) (*E521, error) {
+	u, err := hashToField(msg, dst, 2)
+	if err != nil {
+		return nil, err
+	}
+	Q := mapToCurveElligator2(&u[0]).add(mapToCurveElligator2(&u[1]))
+	return Q.clearCofactor().toAffine(), nil
+}
+
+/*
+Encodes msg to a point of the prime order subgroup of E521, as
+encode_to_curve in RFC 9380 section 3. About half the cost of HashToCurve,
+but the output is not uniformly distributed: only for uses that tolerate a
+nonuniform encoding.
+*/
+func EncodeToCurve(msg, dst []byte) (*E521, error) {
+	u, err := hashToField(msg, dst, 1)
+	if err != nil {
+		return nil, err
+	}
+	return mapToCurveElligator2(&u[0]).clearCofactor().toAffine(), nil
+}
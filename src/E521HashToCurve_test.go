@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// Elligator 2 followed by the map to E521, as written out in RFC 9380, over big.Int.
+func referenceElligator2(u *big.Int) (*big.Int, *big.Int) {
+	p := new(E521).getP()
+	mod := func(a *big.Int) *big.Int { return a.Mod(a, &p) }
+	inv0 := func(a *big.Int) *big.Int {
+		if mod(new(big.Int).Set(a)).Sign() == 0 {
+			return new(big.Int)
+		}
+		return new(big.Int).ModInverse(a, &p)
+	}
+	isSquare := func(a *big.Int) bool { return mod(new(big.Int).Set(a)).Sign() == 0 || big.Jacobi(a, &p) == 1 }
+	d := big.NewInt(-376014)
+	J := mod(new(big.Int).Mul(new(big.Int).Mul(big.NewInt(2), new(big.Int).Add(big.NewInt(1), d)), inv0(new(big.Int).Sub(big.NewInt(1), d))))
+	K := mod(new(big.Int).Mul(big.NewInt(4), inv0(new(big.Int).Sub(big.NewInt(1), d))))
+	A := mod(new(big.Int).Mul(J, inv0(K)))
+	B := mod(new(big.Int).Mul(inv0(K), inv0(K)))
+	g := func(x *big.Int) *big.Int {
+		x2 := new(big.Int).Mul(x, x)
+		return mod(new(big.Int).Add(new(big.Int).Add(new(big.Int).Mul(x2, x), new(big.Int).Mul(A, x2)), new(big.Int).Mul(B, x)))
+	}
+	sqrt := func(a *big.Int) *big.Int { return new(big.Int).ModSqrt(mod(new(big.Int).Set(a)), &p) }
+
+	x1 := mod(new(big.Int).Neg(new(big.Int).Mul(A, inv0(new(big.Int).Sub(big.NewInt(1), new(big.Int).Mul(u, u))))))
+	if x1.Sign() == 0 {
+		x1 = mod(new(big.Int).Neg(A))
+	}
+	x2 := mod(new(big.Int).Sub(new(big.Int).Neg(x1), A))
+	var x, y *big.Int
+	if isSquare(g(x1)) {
+		x, y = x1, sqrt(g(x1))
+		if y.Bit(0) == 0 {
+			y = mod(y.Neg(y))
+		}
+	} else {
+		x, y = x2, sqrt(g(x2))
+		if y.Bit(0) == 1 {
+			y = mod(y.Neg(y))
+		}
+	}
+	s, t := mod(x.Mul(x, K)), mod(y.Mul(y, K))
+	sp1 := mod(new(big.Int).Add(s, big.NewInt(1)))
+	if t.Sign() == 0 || sp1.Sign() == 0 {
+		return big.NewInt(0), big.NewInt(1)
+	}
+	return mod(new(big.Int).Mul(s, inv0(t))), mod(new(big.Int).Mul(new(big.Int).Sub(s, big.NewInt(1)), inv0(sp1)))
+}
+
+func TestElligator2MatchesReference(t *testing.T) {
+	P := new(E521).getP()
+	rng := rand.New(rand.NewSource(9380))
+	// u = 1 makes 1 + Z u^2 vanish
+	inputs := []*big.Int{big.NewInt(0), big.NewInt(1), new(big.Int).Sub(&P, big.NewInt(1)), big.NewInt(2)}
+	for i := 0; i < 40; i++ {
+		inputs = append(inputs, new(big.Int).Rand(rng, &P))
+	}
+	for _, u := range inputs {
+		Q := mapToCurveElligator2(feFromBig(u)).toAffine()
+		if !Q.IsOnCurve() {
+			t.Fatalf("u = %x: image is not on E521", u)
+		}
+		x, y := referenceElligator2(u)
+		if !Q.Equals(NewE521XY(*x, *y)) {
+			t.Fatalf("u = %x: image differs from the reference", u)
+		}
+	}
+}
+
+func TestHashToCurve(t *testing.T) {
+	dst := []byte("SOAPY-TEST-V01-CS01-with-E521_XOF:cSHAKE256_ELL2_RO_")
+	seen := map[string]bool{}
+	for _, msg := range []string{"", "abc", "abcdef0123456789", string(make([]byte, 1000))} {
+		for _, f := range []func([]byte, []byte) (*E521, error){HashToCurve, EncodeToCurve} {
+			P, err := f([]byte(msg), dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := P.validate(); err != nil {
+				t.Fatalf("%q: %v", msg, err)
+			}
+			again, _ := f([]byte(msg), dst)
+			if !again.Equals(P) {
+				t.Fatalf("%q: hashing is not deterministic", msg)
+			}
+			enc, _ := P.MarshalBinary()
+			if seen[string(enc)] {
+				t.Fatalf("%q: repeated output point", msg)
+			}
+			seen[string(enc)] = true
+		}
+	}
+	P, _ := HashToCurve([]byte("abc"), dst)
+	Q, _ := HashToCurve([]byte("abc"), []byte("another tag"))
+	if P.Equals(Q) {
+		t.Error("distinct tags gave the same point")
+	}
+	long := make([]byte, 300)
+	if _, err := HashToCurve([]byte("abc"), long); err != nil {
+		t.Errorf("oversize tag rejected: %v", err)
+	}
+	if _, err := HashToCurve([]byte("abc"), nil); !errors.Is(err, ErrMalformed) {
+		t.Errorf("empty tag: got %v, want ErrMalformed", err)
+	}
+}
+
+func TestFieldFromWideBytes(t *testing.T) {
+	P := new(E521).getP()
+	rng := rand.New(rand.NewSource(98))
+	for i := 0; i < 50; i++ {
+		b := make([]byte, h2cFieldBytes)
+		rng.Read(b)
+		if i == 0 {
+			for j := range b {
+				b[j] = 0xff
+			}
+		}
+		want := new(big.Int).Mod(new(big.Int).SetBytes(b), &P)
+		if got := feFromWideBytes(new(fieldElement), b).BigInt(); got.Cmp(want) != 0 {
+			t.Fatalf("%x mod p: got %x, want %x", b, got, want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"math/big"
+)
+
+/*
+Variable-time multi-scalar multiplication for public inputs, such as
+signature verification and point validation. Never pass secret scalars:
+running time and memory access depend on the scalar values. Use SecMul or
+E521GenMul for secrets.
+
+Uses Straus' interleaving with width-w NAF recodings: every scalar is
+recoded into signed odd digits at most one in w of which is nonzero, and a
+single chain of doublings is shared by all points.
+*/
+
+// Window width of the NAF recoding; each point needs 2^(w-2) precomputed odd multiples.
+const wnafWidth = 5
+
+// Returns the width-w NAF of k >= 0, least significant digit first.
+func wnaf(k *big.Int, w uint) []int {
+	k = new(big.Int).Set(k)
+	window := big.NewInt(1 << w)
+	half := int64(1) << (w - 1)
+	digit := new(big.Int)
+	naf := make([]int, 0, k.BitLen()+1)
+	for k.Sign() > 0 {
+		d := int64(0)
+		if k.Bit(0) == 1 {
+			d = digit.Mod(k, window).Int64()
+			if d >= half {
+				d -= 1 << w
+			}
+			k.Sub(k, big.NewInt(d))
+		}
+		naf = append(naf, int(d))
+		k.Rsh(k, 1)
+	}
+	return naf
+}
+
+// Returns -p, computed as (-X : Y : Z : -T).
+func (p *e521Ext) neg() *e521Ext {
+	out := *p
+	out.X.Neg(&p.X)
+	out.T.Neg(&p.T)
+	return &out
+}
+
+// Returns P, 3P, 5P, ..., (2^(w-1) - 1)P.
+func oddMultiples(P *e521Ext, w uint) []*e521Ext {
+	table := make([]*e521Ext, 1<<(w-2))
+	table[0] = P
+	P2 := P.double()
+	for i := 1; i < len(table); i++ {
+		table[i] = table[i-1].add(P2)
+	}
+	return table
+}
+
+/*
+Returns the sum of scalars[i] * points[i]. Scalars may be any integer and
+are reduced mod the curve order n = 4r, so all points must lie on the curve.
+Panics if the slices differ in length. Returns the identity for empty input.
+
+Runs in variable time and branches on the scalars, so it must only be used
+when every point and scalar is public, as in signature verification.
+*/
+func MultiScalarMul(points []*E521, scalars []*big.Int) *E521 {
+	if len(points) != len(scalars) {
+		panic("E521: MultiScalarMul called with mismatched points and scalars")
+	}
+	R := new(E521).getR()
+	n := new(big.Int).Lsh(&R, 2)
+	nafs := make([][]int, len(points))
+	tables := make([][]*e521Ext, len(points))
+	length := 0
+	for i := range points {
+		nafs[i] = wnaf(new(big.Int).Mod(scalars[i], n), wnafWidth)
+		tables[i] = oddMultiples(points[i].toExtended(), wnafWidth)
+		if len(nafs[i]) > length {
+			length = len(nafs[i])
+		}
+	}
+	acc := e521ExtIdentity()
+	for j := length - 1; j >= 0; j-- {
+		acc = acc.double()
+		for i, naf := range nafs {
+			if j >= len(naf) || naf[j] == 0 {
+				continue
+			}
+			if d := naf[j]; d > 0 {
+				acc = acc.add(tables[i][d/2])
+			} else {
+				acc = acc.add(tables[i][-d/2].neg())
+			}
+		}
+	}
+	return acc.toAffine()
+}
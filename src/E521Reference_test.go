@@ -0,0 +1,269 @@
+package main
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+	"testing/quick"
+)
+
+/*
+A deliberately simple E521 implementation for differential testing: affine
+Edwards formulas with one modular inversion per operation and textbook
+double-and-add multiplication, all in math/big. Slow and variable time,
+but short enough to check by eye against the curve definition
+
+	x^2 + y^2 = 1 + d x^2 y^2,  d = -376014,  p = 2^521 - 1
+
+	(x1, y1) + (x2, y2) = ((x1 y2 + y1 x2) / (1 + d x1 x2 y1 y2),
+	                       (y1 y2 - x1 x2) / (1 - d x1 x2 y1 y2))
+*/
+type refPoint struct{ x, y *big.Int }
+
+var refD = big.NewInt(-376014)
+
+func refMod(a *big.Int) *big.Int { return a.Mod(a, &e521P) }
+
+func refDiv(a, b *big.Int) *big.Int {
+	return refMod(new(big.Int).Mul(a, new(big.Int).ModInverse(refMod(new(big.Int).Set(b)), &e521P)))
+}
+
+func refIdentity() refPoint { return refPoint{big.NewInt(0), big.NewInt(1)} }
+
+func refFrom(P *E521) refPoint {
+	x, y := P.affine()
+	return refPoint{x, y}
+}
+
+func (a refPoint) add(b refPoint) refPoint {
+	x1x2 := new(big.Int).Mul(a.x, b.x)
+	y1y2 := new(big.Int).Mul(a.y, b.y)
+	dxy := refMod(new(big.Int).Mul(refD, new(big.Int).Mul(x1x2, y1y2)))
+	xNum := new(big.Int).Add(new(big.Int).Mul(a.x, b.y), new(big.Int).Mul(a.y, b.x))
+	yNum := new(big.Int).Sub(y1y2, x1x2)
+	return refPoint{
+		refDiv(xNum, new(big.Int).Add(big.NewInt(1), dxy)),
+		refDiv(yNum, new(big.Int).Sub(big.NewInt(1), dxy)),
+	}
+}
+
+// Double-and-add from the most significant bit; k must be non-negative.
+func (a refPoint) mul(k *big.Int) refPoint {
+	acc := refIdentity()
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		acc = acc.add(acc)
+		if k.Bit(i) == 1 {
+			acc = acc.add(a)
+		}
+	}
+	return acc
+}
+
+func (a refPoint) equals(P *E521) bool {
+	x, y := P.affine()
+	return a.x.Cmp(x) == 0 && a.y.Cmp(y) == 0
+}
+
+// Maps arbitrary bytes to a non-negative scalar, as the fuzz and quick inputs.
+func scalarOf(b []byte) *big.Int { return new(big.Int).SetBytes(b) }
+
+// Random test point kG for a scalar k drawn from rng, with its reference form.
+func randomTestPoint(rng *rand.Rand) (*E521, refPoint) {
+	k := new(big.Int).Rand(rng, &scalarOrder)
+	return E521GenMul(k), refFrom(E521GenPoint(0)).mul(k)
+}
+
+// Fewer random cases in -short mode, as each costs several scalar multiplications.
+func quickConfig(seed int64) *quick.Config {
+	n := 12
+	if testing.Short() {
+		n = 3
+	}
+	return &quick.Config{MaxCount: n, Rand: rand.New(rand.NewSource(seed))}
+}
+
+func TestReferenceImplementation(t *testing.T) {
+	G := refFrom(E521GenPoint(0))
+	if !G.mul(&scalarOrder).equals(E521IdPoint()) {
+		t.Fatal("reference: rG != O")
+	}
+	if !G.add(refPoint{refMod(new(big.Int).Neg(G.x)), G.y}).equals(E521IdPoint()) {
+		t.Fatal("reference: G + (-G) != O")
+	}
+}
+
+func TestSmallMultiplesOfG(t *testing.T) {
+	G := E521GenPoint(0)
+	O := E521IdPoint()
+	cases := []struct {
+		name string
+		got  *E521
+		want *E521
+	}{
+		{"0*O", O.SecMul(big.NewInt(0)), O},
+		{"1*G", G.SecMul(big.NewInt(1)), G},
+		{"G + (-G)", G.Add(G.getOpposite()), O},
+		{"2*G", G.SecMul(big.NewInt(2)), G.Add(G)},
+		{"4*G", G.SecMul(big.NewInt(4)), G.SecMul(big.NewInt(2)).SecMul(big.NewInt(2))},
+		{"r*G", G.SecMul(&scalarOrder), O},
+	}
+	for _, c := range cases {
+		if !c.got.IsOnCurve() || !c.got.Equals(c.want) {
+			gx, gy := c.got.affine()
+			wx, wy := c.want.affine()
+			t.Errorf("%s: got (%x, %x), want (%x, %x)", c.name, gx, gy, wx, wy)
+		}
+	}
+	if G.SecMul(big.NewInt(4)).IsIdentity() {
+		t.Error("4G = O")
+	}
+	if !refFrom(G).mul(big.NewInt(2)).equals(G.SecMul(big.NewInt(2))) {
+		t.Error("2G differs from the reference")
+	}
+}
+
+// Every optimized path against the reference on random inputs.
+func TestArithmeticMatchesReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(376014))
+	rounds := 6
+	if testing.Short() {
+		rounds = 2
+	}
+	G := E521GenPoint(0)
+	for i := 0; i < rounds; i++ {
+		P, refP := randomTestPoint(rng)
+		Q, refQ := randomTestPoint(rng)
+		if !refP.equals(P) {
+			t.Fatal("E521GenMul differs from the reference")
+		}
+		if !refP.add(refQ).equals(P.Add(Q)) {
+			t.Fatal("Add differs from the reference")
+		}
+		if !refP.add(refP).equals(P.toExtended().double().toAffine()) {
+			t.Fatal("double differs from the reference")
+		}
+		k := new(big.Int).Rand(rng, new(big.Int).Lsh(&scalarOrder, 2))
+		if !refP.mul(k).equals(P.SecMul(k)) {
+			t.Fatal("SecMul differs from the reference")
+		}
+		if !refFrom(G).mul(k).equals(E521GenMul(k)) {
+			t.Fatal("E521GenMul differs from the reference")
+		}
+		a, b := new(big.Int).Rand(rng, &scalarOrder), new(big.Int).Rand(rng, &scalarOrder)
+		if !refP.mul(a).add(refQ.mul(b)).equals(MultiScalarMul([]*E521{P, Q}, []*big.Int{a, b})) {
+			t.Fatal("MultiScalarMul differs from the reference")
+		}
+	}
+}
+
+func TestGroupLaws(t *testing.T) {
+	G := E521GenPoint(0)
+	point := func(k []byte) *E521 { return E521GenMul(scalarOf(k)) }
+	laws := map[string]interface{}{
+		"commutativity": func(a, b []byte) bool {
+			P, Q := point(a), point(b)
+			return P.Add(Q).Equals(Q.Add(P))
+		},
+		"associativity": func(a, b, c []byte) bool {
+			P, Q, R := point(a), point(b), point(c)
+			return P.Add(Q).Add(R).Equals(P.Add(Q.Add(R)))
+		},
+		"identity and inverse": func(a []byte) bool {
+			P := point(a)
+			return P.Add(E521IdPoint()).Equals(P) && P.Add(P.getOpposite()).IsIdentity()
+		},
+		"distributivity": func(a, b []byte) bool {
+			k, s := scalarOf(a), scalarOf(b)
+			return G.SecMul(new(big.Int).Add(k, s)).Equals(G.SecMul(k).Add(G.SecMul(s)))
+		},
+		"compatibility": func(a, b []byte) bool {
+			k, s := scalarOf(a), scalarOf(b)
+			ks := new(big.Int).Mod(new(big.Int).Mul(k, s), &scalarOrder)
+			return G.SecMul(s).SecMul(k).Equals(G.SecMul(k).SecMul(s)) && G.SecMul(s).SecMul(k).Equals(G.SecMul(ks))
+		},
+		"order r": func(a []byte) bool {
+			k := scalarOf(a)
+			P := point(a)
+			return P.SecMul(&scalarOrder).IsIdentity() &&
+				G.SecMul(k).Equals(G.SecMul(new(big.Int).Mod(k, &scalarOrder))) &&
+				G.SecMul(new(big.Int).Add(k, big.NewInt(1))).Equals(G.SecMul(k).Add(G))
+		},
+	}
+	for name, law := range laws {
+		if err := quick.Check(law, quickConfig(int64(len(name)))); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func FuzzPointAddition(f *testing.F) {
+	f.Add([]byte{1}, []byte{1})
+	f.Add([]byte{0}, []byte{7})
+	f.Add(scalarOrder.Bytes(), []byte{2})
+	G := refFrom(E521GenPoint(0))
+	f.Fuzz(func(t *testing.T, a, b []byte) {
+		k, s := scalarOf(a), scalarOf(b)
+		if k.BitLen() > 600 || s.BitLen() > 600 {
+			return
+		}
+		P, Q := E521GenMul(k), E521GenMul(s)
+		if !G.mul(k).add(G.mul(s)).equals(P.Add(Q)) {
+			t.Fatalf("%xG + %xG differs from the reference", k, s)
+		}
+	})
+}
+
+func FuzzScalarMultiplication(f *testing.F) {
+	f.Add([]byte{0})
+	f.Add([]byte{1, 0})
+	f.Add(new(big.Int).Sub(&scalarOrder, big.NewInt(1)).Bytes())
+	f.Add(new(big.Int).Lsh(&scalarOrder, 2).Bytes())
+	G := E521GenPoint(0)
+	P := G.SecMul(big.NewInt(3))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		k := scalarOf(b)
+		if k.BitLen() > 600 {
+			return
+		}
+		want := refFrom(P).mul(k)
+		got := []*E521{P.SecMul(k), MultiScalarMul([]*E521{P}, []*big.Int{k}), E521GenMul(new(big.Int).Mul(k, big.NewInt(3)))}
+		for i, Q := range got {
+			if !want.equals(Q) {
+				t.Fatalf("path %d: %x*P differs from the reference", i, k)
+			}
+		}
+	})
+}
+
+func FuzzFieldArithmetic(f *testing.F) {
+	f.Add([]byte{0}, []byte{1})
+	f.Add(e521P.Bytes(), []byte{2})
+	f.Fuzz(func(t *testing.T, a, b []byte) {
+		if len(a) > feBytes || len(b) > feBytes {
+			return
+		}
+		x, y := refMod(scalarOf(a)), refMod(scalarOf(b))
+		fx, fy := feFromBig(x), feFromBig(y)
+		got := []*big.Int{
+			new(fieldElement).Add(fx, fy).BigInt(),
+			new(fieldElement).Sub(fx, fy).BigInt(),
+			new(fieldElement).Mul(fx, fy).BigInt(),
+			new(fieldElement).Invert(fx).BigInt(),
+		}
+		inv := new(big.Int).ModInverse(x, &e521P)
+		if inv == nil {
+			inv = new(big.Int)
+		}
+		want := []*big.Int{
+			refMod(new(big.Int).Add(x, y)),
+			refMod(new(big.Int).Sub(x, y)),
+			refMod(new(big.Int).Mul(x, y)),
+			inv,
+		}
+		for i := range got {
+			if got[i].Cmp(want[i]) != 0 {
+				t.Fatalf("field operation %d on %x, %x: got %x, want %x", i, x, y, got[i], want[i])
+			}
+		}
+	})
+}
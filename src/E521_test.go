@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"os"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestPointEncodingRoundTrip(t *testing.T) {
+	points := []*E521{E521IdPoint(), E521GenPoint(0), E521GenPoint(1), E521GenPoint(0).SecMul(big.NewInt(12345))}
+	for i, P := range points {
+		comp, err := P.MarshalBinary()
+		if err != nil || len(comp) != E521CompressedSize {
+			t.Fatalf("point %d: bad compressed encoding", i)
+		}
+		uncomp := P.MarshalUncompressed()
+		for _, enc := range [][]byte{comp, uncomp} {
+			Q := new(E521)
+			if err := Q.UnmarshalBinary(enc); err != nil {
+				t.Fatalf("point %d: %v", i, err)
+			}
+			if !Q.Equals(P) {
+				t.Errorf("point %d: decoded point differs", i)
+			}
+			again, _ := Q.MarshalBinary()
+			if !bytes.Equal(again, comp) {
+				t.Errorf("point %d: re-encoding is not canonical", i)
+			}
+		}
+	}
+}
+
+func TestPointEncodingRejectsNonCanonical(t *testing.T) {
+	G, _ := E521GenPoint(0).MarshalBinary()
+	P := new(E521).getP()
+
+	unusedBits := append([]byte{}, G...)
+	unusedBits[0] |= 0x02
+	xTooLarge := make([]byte, E521CompressedSize)
+	new(big.Int).Add(&P, big.NewInt(4)).FillBytes(xTooLarge)
+	// x = 1 gives y = 0, which has no encoding with the lsb set
+	oddZero := make([]byte, E521CompressedSize)
+	oddZero[0], oddZero[E521CompressedSize-1] = 0x80, 0x01
+	offCurve := make([]byte, E521UncompressedSize)
+	offCurve[E521CompressedSize-1], offCurve[E521UncompressedSize-1] = 4, 4
+	uncompressedTooLarge := E521GenPoint(0).MarshalUncompressed()
+	x := new(big.Int).SetBytes(uncompressedTooLarge[:E521CompressedSize])
+	x.Add(x, &P).FillBytes(uncompressedTooLarge[:E521CompressedSize])
+
+	cases := map[string][]byte{
+		"unused bits set": unusedBits,
+		"x not reduced":   xTooLarge,
+		"wrong y sign":    oddZero,
+		"off curve":       offCurve,
+		"x + p":           uncompressedTooLarge,
+	}
+	for name, enc := range cases {
+		if err := new(E521).UnmarshalBinary(enc); !errors.Is(err, ErrInvalidPoint) {
+			t.Errorf("%s: got %v, want ErrInvalidPoint", name, err)
+		}
+	}
+	if err := new(E521).UnmarshalBinary(G[:65]); !errors.Is(err, ErrMalformed) {
+		t.Errorf("short input: got %v, want ErrMalformed", err)
+	}
+}
+
+func TestExtendedDoublingMatchesAddition(t *testing.T) {
+	P := E521GenPoint(0)
+	for i := 0; i < 16; i++ {
+		ext := P.toExtended()
+		if !ext.double().toAffine().Equals(ext.add(ext).toAffine()) {
+			t.Fatalf("2P != P + P at step %d", i)
+		}
+		if !ext.add(e521ExtIdentity()).toAffine().Equals(P) {
+			t.Fatalf("P + O != P at step %d", i)
+		}
+		P = P.Add(E521GenPoint(0))
+	}
+}
+
+func TestSecMulBlindedResults(t *testing.T) {
+	G := E521GenPoint(0)
+	R := G.getR()
+	n := new(big.Int).Lsh(&R, 2)
+	sum := E521IdPoint()
+	for k := int64(0); k < 8; k++ {
+		if !G.SecMul(big.NewInt(k)).Equals(sum) {
+			t.Fatalf("%dG differs from repeated addition", k)
+		}
+		sum = sum.Add(G)
+	}
+	s := new(big.Int).Sub(&R, big.NewInt(12345))
+	want := G.SecMul(s)
+	for i := 0; i < 4; i++ {
+		if !G.SecMul(s).Equals(want) {
+			t.Fatal("blinded multiplication is not deterministic")
+		}
+	}
+	// scalars outside [0, n) are reduced mod n before blinding
+	for _, k := range []*big.Int{new(big.Int).Add(s, n), new(big.Int).Sub(s, n), new(big.Int).Add(s, new(big.Int).Lsh(n, 70))} {
+		if !G.SecMul(k).Equals(want) {
+			t.Errorf("s + %v*n gave a different point", new(big.Int).Div(new(big.Int).Sub(k, s), n))
+		}
+	}
+	if !G.SecMul(new(big.Int).Sub(&R, big.NewInt(1))).Add(G).IsIdentity() {
+		t.Error("(r-1)G + G != O")
+	}
+}
+
+/*
+Compares the running time of SecMul for the shortest nonzero scalar
+against full length scalars. Before the fixed-iteration ladder
+the two classes differed by two orders of magnitude; they must now be
+indistinguishable up to measurement noise.
+
+Wall-clock ratios are unreliable on loaded machines, so the test only runs
+when SOAPY_TIMING_TESTS=1 is set, on an otherwise idle machine.
+*/
+func TestSecMulTimingIndependentOfScalar(t *testing.T) {
+	if os.Getenv("SOAPY_TIMING_TESTS") != "1" {
+		t.Skip("timing test skipped, set SOAPY_TIMING_TESTS=1 to run it")
+	}
+	const samples = 41
+	G := E521GenPoint(0)
+	R := G.getR()
+	short := big.NewInt(1)
+	long := make([]*big.Int, samples)
+	for i := range long {
+		long[i] = new(big.Int).Sub(&R, big.NewInt(int64(i+1)))
+	}
+	var tShort, tLong []time.Duration
+	G.SecMul(short) // warm up
+	for i := 0; i < samples; i++ {
+		start := time.Now()
+		G.SecMul(short)
+		tShort = append(tShort, time.Since(start))
+		start = time.Now()
+		G.SecMul(long[i])
+		tLong = append(tLong, time.Since(start))
+	}
+	median := func(d []time.Duration) time.Duration {
+		sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+		return d[len(d)/2]
+	}
+	ms, ml := median(tShort), median(tLong)
+	ratio := float64(ml) / float64(ms)
+	t.Logf("median SecMul time: short scalar %v, full scalar %v (ratio %.3f)", ms, ml, ratio)
+	if ratio < 0.8 || ratio > 1.25 {
+		t.Errorf("SecMul time depends on the scalar: ratio %.3f", ratio)
+	}
+}
+
+func TestGenMulMatchesSecMul(t *testing.T) {
+	G := E521GenPoint(0)
+	R := G.getR()
+	scalars := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(15), big.NewInt(16), big.NewInt(12345),
+		new(big.Int).Sub(&R, big.NewInt(1)), &R, new(big.Int).Lsh(&R, 2), new(big.Int).Neg(big.NewInt(7))}
+	for _, s := range scalars {
+		if !E521GenMul(s).Equals(G.SecMul(s)) {
+			t.Errorf("E521GenMul(%v) != G.SecMul(%v)", s, s)
+		}
+	}
+	if !E521GenPoint(0).Equals(G) || E521GenPoint(0) == G {
+		t.Error("E521GenPoint must return equal, distinct copies of the cached generator")
+	}
+}
+
+func BenchmarkGeneratorSecMul(b *testing.B) {
+	G, R := E521GenPoint(0), new(E521).getR()
+	s := new(big.Int).Sub(&R, big.NewInt(12345))
+	for i := 0; i < b.N; i++ {
+		G.SecMul(s)
+	}
+}
+
+func BenchmarkGeneratorGenMul(b *testing.B) {
+	R := new(E521).getR()
+	s := new(big.Int).Sub(&R, big.NewInt(12345))
+	E521GenMul(s) // build the table outside the timed loop
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		E521GenMul(s)
+	}
+}
+
+func TestMultiScalarMulMatchesSecMul(t *testing.T) {
+	G := E521GenPoint(0)
+	R := G.getR()
+	points := []*E521{G, G.SecMul(big.NewInt(7)), G.SecMul(new(big.Int).Sub(&R, big.NewInt(99))), E521IdPoint()}
+	scalars := []*big.Int{new(big.Int).Sub(&R, big.NewInt(5)), big.NewInt(0), big.NewInt(-31), new(big.Int).Lsh(&R, 3)}
+	want := E521IdPoint()
+	for i := range points {
+		want = want.Add(points[i].SecMul(scalars[i]))
+		if got := MultiScalarMul(points[:i+1], scalars[:i+1]); !got.Equals(want) {
+			t.Fatalf("sum of %d terms differs from SecMul", i+1)
+		}
+	}
+	if !MultiScalarMul(nil, nil).IsIdentity() {
+		t.Error("empty sum is not the identity")
+	}
+	for _, k := range []int64{1, 15, 16, 17, 31, 32, 1<<40 + 12345} {
+		naf := wnaf(big.NewInt(k), wnafWidth)
+		sum := new(big.Int)
+		for j := len(naf) - 1; j >= 0; j-- {
+			sum.Add(sum.Lsh(sum, 1), big.NewInt(int64(naf[j])))
+		}
+		if sum.Int64() != k {
+			t.Errorf("wNAF of %d evaluates to %v", k, sum)
+		}
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	msg := []byte("benchmark message")
+	key := KeyObj{}
+	generateKeyPair(&key, "pw", "bench")
+	V, _ := key.publicKey()
+	raw, _ := signWithKey([]byte("pw"), &msg)
+	sig, _ := decodeSignature(raw)
+	if !verify(V, sig, &msg) {
+		b.Fatal("signature does not verify")
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		verify(V, sig, &msg)
+	}
+}
@@ -3,26 +3,51 @@ package main
 import (
 	"encoding/hex"
 	"errors"
-	"regexp"
 	"strings"
 	"unicode/utf8"
 )
 
 // refactor
 const (
-	soapMessageBegin = "-----------BEGIN-SOAP-MESSAGE-----------\n"
-	soapMessageEnd   = "------------END-SOAP-MESSAGE------------"
-	signatureBegin   = "----------BEGIN-SOAP-SIGNATURE----------\n"
-	signatureEnd     = "-----------END-SOAP-SIGNATURE-----------"
+	soapMessageBegin  = "-----------BEGIN-SOAP-MESSAGE-----------\n"
+	soapMessageEnd    = "------------END-SOAP-MESSAGE------------"
+	signatureBegin    = "----------BEGIN-SOAP-SIGNATURE----------\n"
+	signatureEnd      = "-----------END-SOAP-SIGNATURE-----------"
+	detachedBegin     = "-----BEGIN-SOAP-DETACHED-SIGNATURE------\n"
+	detachedEnd       = "------END-SOAP-DETACHED-SIGNATURE-------"
+	clearSignedBegin  = "-------BEGIN-SOAP-SIGNED-MESSAGE--------\n"
+	muSigNonceBegin   = "---------BEGIN-SOAP-MUSIG-NONCE---------\n"
+	muSigNonceEnd     = "----------END-SOAP-MUSIG-NONCE----------"
+	muSigPartialBegin = "--------BEGIN-SOAP-MUSIG-PARTIAL--------\n"
+	muSigPartialEnd   = "---------END-SOAP-MUSIG-PARTIAL---------"
 )
 
+// Number of hex characters per line inside SOAP armor
+const soapLineLength = 40
+
+// Formats data in SOAP armor as getSOAP does, for callers without a window.
+func formatSOAP(data []byte, l1, l2 string) string {
+	h := hex.EncodeToString(data)
+	var sb strings.Builder
+	sb.WriteString(l1)
+	for i := 0; i < len(h); i += soapLineLength {
+		end := i + soapLineLength
+		if end > len(h) {
+			end = len(h)
+		}
+		sb.WriteString(h[i:end] + "\n")
+	}
+	sb.WriteString(l2)
+	return sb.String()
+}
+
 // Formats a given message to SOAP format as specified in docs
 func getSOAP(message *string, ctx *WindowCtx, l1, l2 string) *string {
 
 	// string length
 	sl := utf8.RuneCountInString(*message)
 	// set line length
-	lineLength := 40
+	lineLength := soapLineLength
 
 	var sb strings.Builder
 	sb.Write([]byte(l1))
@@ -56,17 +81,29 @@ func getSOAP(message *string, ctx *WindowCtx, l1, l2 string) *string {
 	return &res
 }
 
-// Parses a SOAP formatted string by removing header and footer and all newlines.
+/*
+Parses a SOAP formatted string by removing header and footer and all newlines.
+CRLF line endings and trailing whitespace after the footer are accepted.
+Where a signature is expected, a clear-signed message is accepted too and
+returned as its signature with the canonical text embedded, which verifies
+like an opaque signature. Returns an error wrapping ErrMalformed if the
+armor or the hex inside it is invalid.
+*/
 func parseSOAP(message *string, l1, l2 string) (*[]byte, error) {
-	lines := strings.Split(*message, "\n")
-	if lines[0]+"\n" == l1 &&
-		lines[len(lines)-1] == l2 {
-		str := strings.Join(lines[1:len(lines)-1], "\n")
-		regex := regexp.MustCompile(`[\r\n]+`)
-		strippedString := regex.ReplaceAllString(str, "")
-		res, _ := hex.DecodeString(strippedString)
-		return &res, nil
-	} else {
-		return nil, errors.New("malformed text, unable to parse")
+	if l1 == signatureBegin && isClearSigned(*message) {
+		return clearSignedSignature(strings.TrimLeft(*message, "\r\n"))
+	}
+	text := strings.TrimRight(strings.ReplaceAll(*message, "\r\n", "\n"), " \t\r\n")
+	if len(text) < len(l1)+len(l2) || !strings.HasPrefix(text, l1) || !strings.HasSuffix(text, l2) {
+		return nil, wrapErr(ErrMalformed, errors.New("unable to parse SOAP armor"))
+	}
+	body := text[len(l1) : len(text)-len(l2)]
+	if body != "" && !strings.HasSuffix(body, "\n") {
+		return nil, wrapErr(ErrMalformed, errors.New("SOAP footer must start a new line"))
+	}
+	res, err := hex.DecodeString(strings.ReplaceAll(body, "\n", ""))
+	if err != nil {
+		return nil, wrapErr(ErrMalformed, err)
 	}
+	return &res, nil
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseSOAP(t *testing.T) {
+	data := []byte("some cryptogram bytes, long enough to wrap over several lines")
+	armored := formatSOAP(data, soapMessageBegin, soapMessageEnd)
+	for name, text := range map[string]string{
+		"as written":        armored,
+		"trailing newline":  armored + "\n",
+		"CRLF line endings": strings.ReplaceAll(armored, "\n", "\r\n"),
+	} {
+		got, err := parseSOAP(&text, soapMessageBegin, soapMessageEnd)
+		if err != nil || !bytes.Equal(*got, data) {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+	bad := map[string]string{
+		"empty":           "",
+		"header only":     soapMessageBegin,
+		"wrong armor":     formatSOAP(data, signatureBegin, signatureEnd),
+		"odd hex length":  soapMessageBegin + "abc\n" + soapMessageEnd,
+		"non-hex content": soapMessageBegin + "zz\n" + soapMessageEnd,
+	}
+	for name, text := range bad {
+		if _, err := parseSOAP(&text, soapMessageBegin, soapMessageEnd); err == nil {
+			t.Errorf("%s: parsed without error", name)
+		}
+	}
+}
+
+func FuzzParseSOAP(f *testing.F) {
+	msg := []byte("message")
+	cg, _ := encryptWithPW([]byte("pw"), &msg)
+	f.Add(formatSOAP(*cg, soapMessageBegin, soapMessageEnd))
+	f.Add(formatSOAP(nil, soapMessageBegin, soapMessageEnd))
+	f.Add(soapMessageBegin)
+	f.Add("\n" + soapMessageEnd)
+	f.Fuzz(func(t *testing.T, text string) {
+		data, err := parseSOAP(&text, soapMessageBegin, soapMessageEnd)
+		if err != nil {
+			return
+		}
+		again := formatSOAP(*data, soapMessageBegin, soapMessageEnd)
+		round, err := parseSOAP(&again, soapMessageBegin, soapMessageEnd)
+		if err != nil || !bytes.Equal(*round, *data) {
+			t.Fatalf("re-armored data did not parse back: %v", err)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"math/big"
+	"sort"
+)
+
+// A message, its signature and the public key it is claimed to verify under.
+type SignedItem struct {
+	PubKey  Point
+	Message []byte
+	Sig     *Signature
+}
+
+// A batch candidate whose encodings and hash have already been checked.
+type batchEntry struct {
+	index int      // position in the caller's slice
+	key   int      // index into the batch's distinct public keys
+	U     Point    // decoded commitment
+	H, Z  *big.Int // signature scalars
+}
+
+// The items of a batch that share a curve, with their distinct public keys.
+type curveBatch struct {
+	curve    Curve
+	keys     []Point
+	keyIndex map[string]int
+	entries  []*batchEntry
+}
+
+// Width in bytes of the random coefficient applied to each signature.
+const batchCoeffBytes = 16
+
+/*
+Verifies many signatures at once and returns the indices of the items
+that fail, in ascending order; an empty result means every signature is
+valid. As for verify, each signature must be committed, recording its
+commitment U; legacy signatures are reported as invalid.
+
+For random 128 bit a_i the batch is accepted when
+
+	sum(a_i z_i) G + sum_j (sum_{i: key j} a_i h_i) V_j - sum(a_i U_i) = O
+
+which costs a single multi-scalar multiplication, with each distinct key
+contributing one term however many items use it. If the combined check
+fails the batch is split in half and each half is checked again until
+the invalid items are isolated, and single items are decided by verify.
+
+Every U_i and V_j must be a point of the prime order subgroup, so a U
+with a small-order component, which verify rejects, is rejected here too
+and the batch reports exactly the items verify would.
+*/
+func VerifyBatch(items []SignedItem) []int {
+	var bad []int
+	batches := make([]*curveBatch, len(supportedCurves))
+	for i := range items {
+		item := &items[i]
+		if item.PubKey == nil || item.Sig == nil {
+			bad = append(bad, i)
+			continue
+		}
+		curve := item.PubKey.Curve()
+		b := batches[curve.ID()]
+		if b == nil {
+			b = &curveBatch{curve: curve, keyIndex: map[string]int{}}
+			batches[curve.ID()] = b
+		}
+		enc, _ := item.PubKey.MarshalBinary()
+		k, seen := b.keyIndex[string(enc)]
+		if !seen {
+			if item.PubKey.validate() != nil {
+				bad = append(bad, i)
+				continue
+			}
+			k = len(b.keys)
+			b.keyIndex[string(enc)] = k
+			b.keys = append(b.keys, item.PubKey)
+		}
+		entry, ok := prepareBatchEntry(curve, item)
+		if !ok {
+			bad = append(bad, i)
+			continue
+		}
+		entry.index, entry.key = i, k
+		b.entries = append(b.entries, entry)
+	}
+	for _, b := range batches {
+		if b != nil {
+			bad = append(bad, b.bisect(items, b.entries)...)
+		}
+	}
+	sort.Ints(bad)
+	return bad
+}
+
+// Decodes U, which must lie in the prime order subgroup, and checks that h is the hash of U and the message, as verify would.
+func prepareBatchEntry(curve Curve, item *SignedItem) (*batchEntry, bool) {
+	sig := item.Sig
+	if sig.checkCanonical(curve) != nil {
+		return nil, false
+	}
+	if !bytes.Equal(sig.F, keyFingerprint(item.PubKey)) {
+		return nil, false
+	}
+	U, err := curve.DecodePoint(sig.U)
+	if err != nil || U.validate() != nil {
+		return nil, false
+	}
+	hP := sigChallenge(sig.V, U, item.Message)
+	h := make([]byte, len(hP))
+	sig.H.FillBytes(h)
+	if subtle.ConstantTimeCompare(hP, h) != 1 {
+		return nil, false
+	}
+	return &batchEntry{U: U, H: sig.H, Z: sig.Z}, true
+}
+
+// Returns the indices of the invalid entries, checking halves of a failing batch recursively.
+func (b *curveBatch) bisect(items []SignedItem, entries []*batchEntry) []int {
+	switch {
+	case len(entries) == 0:
+		return nil
+	case len(entries) == 1:
+		item := &items[entries[0].index]
+		if !verify(item.PubKey, item.Sig, &item.Message) {
+			return []int{entries[0].index}
+		}
+		return nil
+	}
+	if ok, err := b.check(entries); err == nil && ok {
+		return nil
+	}
+	mid := len(entries) / 2
+	return append(b.bisect(items, entries[:mid]), b.bisect(items, entries[mid:])...)
+}
+
+// Evaluates the combined batch equation under fresh random coefficients.
+func (b *curveBatch) check(entries []*batchEntry) (bool, error) {
+	coeffs, err := readRandomBytes(rand.Reader, batchCoeffBytes*len(entries))
+	if err != nil {
+		return false, err
+	}
+	R := b.curve.Order()
+	gScalar := new(big.Int)
+	keyScalars := make([]*big.Int, len(b.keys))
+	for i := range keyScalars {
+		keyScalars[i] = new(big.Int)
+	}
+	points := []Point{b.curve.Generator()}
+	scalars := []*big.Int{gScalar}
+	for i, e := range entries {
+		a := new(big.Int).SetBytes(coeffs[i*batchCoeffBytes : (i+1)*batchCoeffBytes])
+		gScalar.Add(gScalar, new(big.Int).Mul(a, e.Z))
+		keyScalars[e.key].Add(keyScalars[e.key], new(big.Int).Mul(a, e.H))
+		points = append(points, e.U)
+		scalars = append(scalars, a.Neg(a))
+	}
+	gScalar.Mod(gScalar, R)
+	points = append(points, b.keys...)
+	scalars = append(scalars, keyScalars...)
+	return b.curve.MultiScalarMul(points, scalars).IsIdentity(), nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+// Signs count messages, alternating between two keys.
+func testSignedItems(t testing.TB, count int) []SignedItem {
+	t.Helper()
+	pws := []string{"alice", "bob"}
+	items := make([]SignedItem, count)
+	for i := range items {
+		pw := pws[i%len(pws)]
+		msg := []byte(fmt.Sprintf("artifact-%d.tar.gz", i))
+		raw, err := signWithKey([]byte(pw), &msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, err := decodeSignature(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		items[i] = SignedItem{PubKey: testPublicKey(t, pw), Message: msg, Sig: sig}
+	}
+	return items
+}
+
+func TestVerifyBatch(t *testing.T) {
+	items := testSignedItems(t, 12)
+	if bad := VerifyBatch(items); len(bad) != 0 {
+		t.Fatalf("valid batch reported bad items %v", bad)
+	}
+	for i := range items {
+		if !verify(items[i].PubKey, items[i].Sig, &items[i].Message) {
+			t.Fatalf("item %d does not verify individually", i)
+		}
+	}
+
+	items[2].Message = []byte("tampered")
+	z := *items[5].Sig
+	z.Z = new(big.Int).Add(z.Z, big.NewInt(1))
+	items[5].Sig = &z
+	items[7].PubKey = items[6].PubKey // signed by the other key
+	u := *items[9].Sig
+	u.U = items[10].Sig.U
+	items[9].Sig = &u
+	items[11].Sig = schnorrSign(E521Curve, privateScalar(E521Curve, []byte("bob")), items[11].Message, sigVersionLegacy)
+
+	if bad, want := VerifyBatch(items), []int{2, 5, 7, 9, 11}; !reflect.DeepEqual(bad, want) {
+		t.Errorf("bad items %v, want %v", bad, want)
+	}
+	if bad := VerifyBatch(nil); len(bad) != 0 {
+		t.Errorf("empty batch reported %v", bad)
+	}
+}
+
+func BenchmarkVerifyIndividually(b *testing.B) {
+	items := testSignedItems(b, 64)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i := range items {
+			verify(items[i].PubKey, items[i].Sig, &items[i].Message)
+		}
+	}
+}
+
+func BenchmarkVerifyBatch(b *testing.B) {
+	items := testSignedItems(b, 64)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		VerifyBatch(items)
+	}
+}
+
+// A signer can put a small-order component into U; the batch must reject it as verify does.
+func TestVerifyBatchRejectsTorsionCommitment(t *testing.T) {
+	msg := []byte("artifact-torsion.tar.gz")
+	V := testPublicKey(t, "alice")
+	s := privateScalar(E521Curve, []byte("alice"))
+	f := E521Curve.scalars()
+	k := f.zero().SetUniformBytes(KMACXOF256(&[]byte{1}, &msg, 512, "N"))
+	T := NewE521XY(*big.NewInt(0), *new(big.Int).Sub(&e521P, big.NewInt(1))) // order 2
+	U := E521Curve.ScalarBaseMult(k.BigInt()).AddPoint(T)
+	h := sigChallenge(sigVersionCommitted, U, msg)
+	hs := f.zero().SetUniformBytes(h)
+	z := f.zero().Sub(k, hs.Mul(hs, s))
+	uBytes, _ := U.MarshalBinary()
+	sig := &Signature{H: new(big.Int).SetBytes(h), Z: z.BigInt(), U: uBytes, C: CurveE521, F: keyFingerprint(V), V: sigVersionCommitted}
+	if verify(V, sig, &msg) {
+		t.Fatal("verify accepted a commitment with a small-order component")
+	}
+	items := append(testSignedItems(t, 6), SignedItem{PubKey: V, Message: msg, Sig: sig})
+	if bad := VerifyBatch(items); !reflect.DeepEqual(bad, []int{6}) {
+		t.Errorf("bad items %v, want [6]", bad)
+	}
+}
@@ -4,7 +4,7 @@
 
 # Loop through the list of files
 	# Compile the file
-go build view.go model.go sponge.go keccakf.go utilities.go cSHAKE.go dialogs.go controller.go keyTable.go E521.go SOAP_formatter.go E521Tests.go
+go build view.go model.go sponge.go keccakf.go utilities.go cSHAKE.go dialogs.go controller.go keyTable.go E521.go SOAP_formatter.go padding.go compress.go options.go errors.go E521Extended.go field521.go E521Base.go E521MultiMul.go batchVerify.go scalar.go curve.go ed448.go E521HashToCurve.go detachedSignature.go cli.go clearSigned.go ed521.go musig.go field448.go terminal_linux.go
 
 # # Run the executable
  ./view
\ No newline at end of file
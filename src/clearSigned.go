@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+/*
+Clear-signed messages keep the signed text readable without this tool, in
+the manner of OpenPGP cleartext signatures (RFC 4880 section 7):
+
+	-------BEGIN-SOAP-SIGNED-MESSAGE--------
+	the message, dash-escaped
+	----------BEGIN-SOAP-SIGNATURE----------
+	hex of the signature, without the message
+	-----------END-SOAP-SIGNATURE-----------
+
+The signature covers the canonical form of the text. Trailing spaces and
+tabs are removed from every line and lines are joined with CRLF, so
+editors and mail transports that change line endings or strip trailing
+blanks do not break it. The line break before the signature header is part
+of the armor, not of the text. Lines of the text starting with a dash are
+written with "- " in front, so they cannot be mistaken for armor lines.
+*/
+
+// Reports whether message, after any leading line breaks, starts with the clear-signed header.
+func isClearSigned(message string) bool {
+	return strings.HasPrefix(strings.TrimLeft(message, "\r\n"), strings.TrimSuffix(clearSignedBegin, "\n"))
+}
+
+// Splits text into lines with trailing blanks removed, accepting LF, CRLF and CR line endings.
+func textLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(strings.ReplaceAll(text, "\r", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return lines
+}
+
+// The canonical form of text that a clear signature covers.
+func canonicalText(text string) []byte {
+	return []byte(strings.Join(textLines(text), "\r\n"))
+}
+
+// Formats text and its encoded signature as a clear-signed message.
+func formatClearSigned(text string, signature []byte) string {
+	var sb strings.Builder
+	sb.WriteString(clearSignedBegin)
+	for _, line := range textLines(text) {
+		if strings.HasPrefix(line, "-") {
+			sb.WriteString("- ")
+		}
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString(formatSOAP(signature, signatureBegin, signatureEnd))
+	return sb.String()
+}
+
+/*
+Splits a clear-signed message into its text, with dash-escaping undone, and
+its signature. Blanks at the end of the text and header lines are ignored.
+Returns an error wrapping ErrMalformed if the armor is invalid, including
+unescaped lines that start with a dash, or ErrUnsupportedVersion if the
+signature does not commit to its nonce.
+*/
+func parseClearSigned(message string) (string, *Signature, error) {
+	rest := strings.ReplaceAll(message, "\r\n", "\n")
+	var lines []string
+	for first := true; ; first = false {
+		i := strings.IndexByte(rest, '\n')
+		if i < 0 {
+			return "", nil, wrapErr(ErrMalformed, errors.New("clear-signed message has no signature"))
+		}
+		line := strings.TrimRight(rest[:i], " \t")
+		rest = rest[i+1:]
+		switch {
+		case first:
+			if line+"\n" != clearSignedBegin {
+				return "", nil, wrapErr(ErrMalformed, errors.New("not a clear-signed message"))
+			}
+			continue
+		case line+"\n" == signatureBegin:
+		case strings.HasPrefix(line, "- "):
+			lines = append(lines, line[2:])
+			continue
+		case strings.HasPrefix(line, "-"):
+			return "", nil, wrapErr(ErrMalformed, errors.New("line starting with a dash is not escaped"))
+		default:
+			lines = append(lines, line)
+			continue
+		}
+		break
+	}
+	rest = signatureBegin + rest
+	data, err := parseSOAP(&rest, signatureBegin, signatureEnd)
+	if err != nil {
+		return "", nil, err
+	}
+	sig, err := decodeSignature(data)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(sig.M) != 0 {
+		return "", nil, wrapErr(ErrMalformed, errors.New("clear signature embeds a message"))
+	}
+	if sig.V != sigVersionCommitted {
+		return "", nil, wrapErr(ErrUnsupportedVersion, errors.New("clear signature without a nonce commitment"))
+	}
+	return strings.Join(lines, "\n"), sig, nil
+}
+
+/*
+Parses a clear-signed message into an encoded signature that embeds the
+canonical form of the text, so parseSOAP can hand it to the same
+verification as an opaque signature.
+*/
+func clearSignedSignature(message string) (*[]byte, error) {
+	text, sig, err := parseClearSigned(message)
+	if err != nil {
+		return nil, err
+	}
+	sig.M = canonicalText(text)
+	data, err := encodeSignature(sig)
+	if err != nil {
+		return nil, wrapErr(ErrMalformed, err)
+	}
+	return data, nil
+}
+
+// Clear-signs text under the key derived from pw on curve.
+func clearSign(curve Curve, pw []byte, text string) (string, error) {
+	encoded, err := encodeSignature(signatureOn(curve, pw, canonicalText(text)))
+	if err != nil {
+		return "", wrapErr(ErrMalformed, err)
+	}
+	return formatClearSigned(text, *encoded), nil
+}
+
+/*
+Parses and verifies a clear-signed message through parseSOAP. The verifying
+key is looked up in kt by the signer fingerprint, or is fallback for
+signatures without one.
+
+	return: the signed text with LF line endings and the signer's key, or an
+	error wrapping ErrMalformed, ErrUnsupportedVersion, errUnknownSigner or
+	ErrAuthFailed
+*/
+func verifyClearSigned(kt *KeyTable, fallback *KeyObj, message string) (string, *KeyObj, error) {
+	if !isClearSigned(message) {
+		return "", nil, wrapErr(ErrMalformed, errors.New("not a clear-signed message"))
+	}
+	data, err := parseSOAP(&message, signatureBegin, signatureEnd)
+	if err != nil {
+		return "", nil, err
+	}
+	sig, err := decodeSignature(data)
+	if err != nil {
+		return "", nil, err
+	}
+	key, err := kt.signerKey(sig.F, fallback)
+	if err != nil {
+		return "", nil, err
+	}
+	V, err := key.publicKey()
+	if err != nil {
+		return "", nil, err
+	}
+	if !verify(V, sig, &sig.M) {
+		return "", nil, ErrAuthFailed
+	}
+	return strings.ReplaceAll(string(sig.M), "\r\n", "\n"), key, nil
+}
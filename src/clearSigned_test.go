@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestClearSigned(t *testing.T) {
+	alice := testKeyObj(t, E521Curve, "alice", "alice")
+	kt := &KeyTable{keyList: map[string]KeyObj{"alice": *alice}}
+	texts := []string{
+		"",
+		"hello",
+		"hello\n",
+		"line one\nline two  \n\n",
+		"-----------END-SOAP-SIGNATURE-----------\n--\n- already escaped\n",
+		"----------BEGIN-SOAP-SIGNATURE----------\nnot a signature",
+		"unicode: ünïcödé ✓\ttab\t",
+	}
+	for _, text := range texts {
+		signed, err := clearSign(E521Curve, []byte("alice"), text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(signed, "\n") {
+			if strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "- ") && !strings.HasSuffix(line, "-") {
+				t.Errorf("%q: unescaped line %q", text, line)
+			}
+		}
+		armor := strings.Index(signed, signatureBegin)
+		transported := map[string]string{
+			"as signed":       signed,
+			"CRLF":            strings.ReplaceAll(signed, "\n", "\r\n"),
+			"trailing blanks": strings.ReplaceAll(signed[:armor], "\n", " \t\n") + signed[armor:],
+		}
+		for name, message := range transported {
+			got, key, err := verifyClearSigned(kt, nil, message)
+			if err != nil {
+				t.Fatalf("%q, %s: %v", text, name, err)
+			}
+			if key.Id != "alice" || string(canonicalText(got)) != string(canonicalText(text)) {
+				t.Errorf("%q, %s: got %q signed by %s", text, name, got, key.Id)
+			}
+		}
+	}
+}
+
+func TestClearSignedRejected(t *testing.T) {
+	alice := testKeyObj(t, E521Curve, "alice", "alice")
+	bob := testKeyObj(t, E521Curve, "bob", "bob")
+	kt := &KeyTable{keyList: map[string]KeyObj{"alice": *alice}}
+	signed, _ := clearSign(E521Curve, []byte("alice"), "pay bob 10\n- and more\n")
+	byBob, _ := clearSign(E521Curve, []byte("bob"), "pay bob 10\n")
+	msg := []byte("pay bob 10")
+	embedded, _ := signWithKey([]byte("alice"), &msg)
+	cases := []struct {
+		name    string
+		message string
+		want    error
+	}{
+		{"modified text", strings.Replace(signed, "10", "100", 1), ErrAuthFailed},
+		{"inserted line", strings.Replace(signed, "pay", "pay\nnot", 1), ErrAuthFailed},
+		{"unescaped dash", strings.Replace(signed, "- - and", "-- and", 1), ErrMalformed},
+		{"no signature", signed[:strings.Index(signed, signatureBegin)], ErrMalformed},
+		{"no header", signed[len(clearSignedBegin):], ErrMalformed},
+		{"truncated signature", signed[:len(signed)-len(signatureEnd)], ErrMalformed},
+		{"embedded message", clearSignedBegin + "pay bob 10\n" + formatSOAP(*embedded, signatureBegin, signatureEnd), ErrMalformed},
+		{"unknown signer", byBob, errUnknownSigner},
+	}
+	for _, c := range cases {
+		if _, _, err := verifyClearSigned(kt, bob, c.message); !errors.Is(err, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.want)
+		}
+	}
+}
+
+// Clear-signed and opaque signatures take the same parseSOAP path the verify button uses.
+func TestClearSignedThroughParseSOAP(t *testing.T) {
+	alice := testKeyObj(t, E521Curve, "alice", "alice")
+	V, _ := alice.publicKey()
+	text := "pay bob 10  \n- and more\n"
+	msg := []byte(text)
+	opaque, _ := signWithKeyOn(E521Curve, []byte("alice"), &msg)
+	signed, _ := clearSign(E521Curve, []byte("alice"), text)
+	for name, message := range map[string]string{
+		"opaque":       formatSOAP(*opaque, signatureBegin, signatureEnd),
+		"clear-signed": "\r\n" + strings.ReplaceAll(signed, "\n", "\r\n"),
+	} {
+		data, err := parseSOAP(&message, signatureBegin, signatureEnd)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		sig, err := decodeSignature(data)
+		if err != nil || !verifyMessage(V, sig, &sig.M) {
+			t.Errorf("%s: signature rejected: %v", name, err)
+		}
+	}
+	if _, err := parseSOAP(&signed, soapMessageBegin, soapMessageEnd); !errors.Is(err, ErrMalformed) {
+		t.Errorf("clear-signed message parsed as a cryptogram: %v", err)
+	}
+
+	canonical := canonicalText(text)
+	legacy := schnorrSign(E521Curve, privateScalar(E521Curve, []byte("alice")), canonical, sigVersionLegacy)
+	enc, _ := encodeSignature(legacy)
+	message := formatClearSigned(text, *enc)
+	if _, err := parseSOAP(&message, signatureBegin, signatureEnd); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("legacy clear signature: got %v, want ErrUnsupportedVersion", err)
+	}
+}
+
+func TestCanonicalText(t *testing.T) {
+	for in, want := range map[string]string{
+		"a \nb\t\n":   "a\r\nb\r\n",
+		"a\r\nb\r":    "a\r\nb\r\n",
+		" lead  ":     " lead",
+		"\n\n":        "\r\n\r\n",
+		"x\r\n\r\ny ": "x\r\n\r\ny",
+	} {
+		if got := string(canonicalText(in)); got != want {
+			t.Errorf("canonicalText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func FuzzParseClearSigned(f *testing.F) {
+	signed, _ := clearSign(E521Curve, []byte("seed"), "seed text\n-dash\n")
+	f.Add(signed)
+	f.Add(clearSignedBegin + "- x\n" + signatureBegin + signatureEnd)
+	f.Add(clearSignedBegin)
+	f.Fuzz(func(t *testing.T, message string) {
+		text, sig, err := parseClearSigned(message)
+		if err != nil {
+			if !errors.Is(err, ErrMalformed) && !errors.Is(err, ErrUnsupportedVersion) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			return
+		}
+		// the parsed text must survive formatting and parsing again
+		enc, err := encodeSignature(sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, _, err := parseClearSigned(formatClearSigned(text, *enc))
+		if err != nil || string(canonicalText(again)) != string(canonicalText(text)) {
+			t.Fatalf("reformatted %q as %q: %v", text, again, err)
+		}
+	})
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const cliUsage = `usage:
+  soapytool sign -key KEY.SOAP_KEY [-alg Ed521] FILE
+        signs FILE, writing the detached signature to FILE.sig, with the
+        Schnorr scheme of the key's curve or, for E521 keys, with Ed521;
+        the key passphrase is read from the first line of standard input,
+        without echo if it is a terminal
+  soapytool verify -key KEY.SOAP_KEY [-key KEY.SOAP_KEY ...] FILE [SIGNATURE]
+        verifies FILE against SIGNATURE, or FILE.sig if omitted, with
+        the given key whose fingerprint the signature names
+  soapytool encrypt -key KEY.SOAP_KEY [-hide-recipient] FILE
+        encrypts FILE to the public key, writing the armored cryptogram
+        to FILE.soap
+  soapytool decrypt [-key KEY.SOAP_KEY ...] [-o OUTPUT] FILE.soap
+        decrypts with the private key the cryptogram names, or with every
+        given private key if the recipient is hidden, or else with the
+        passphrase read as for sign; the plaintext is written unchanged
+        to OUTPUT, or FILE.soap without its suffix
+
+Without one of these commands the graphical interface starts, and any
+arguments are passed on to GTK.
+`
+
+// Reports whether args start with a command line subcommand rather than GUI arguments.
+func isCLICommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "sign", "verify", "encrypt", "decrypt":
+		return true
+	}
+	return false
+}
+
+/*
+Runs a command line invocation and returns the process exit status: 0 on
+success, 1 if the operation failed (including a bad signature) and 2 for
+usage errors. Passphrases are read from stdin, with a prompt on stderr
+when stdin is a terminal.
+*/
+func runCLI(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, cliUsage)
+		return 2
+	}
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var keyFiles keyFileList
+	flags.Var(&keyFiles, "key", "exported key file, may be repeated for verify and decrypt")
+	hideRecipient := flags.Bool("hide-recipient", false, "leave the recipient key ID out of the cryptogram")
+	output := flags.String("o", "", "file to write the decrypted plaintext to")
+	alg := flags.String("alg", "", "detached signature algorithm, Ed521 for E521 keys")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	files := flags.Args()
+	if (len(keyFiles) == 0 && args[0] != "decrypt") || len(files) == 0 {
+		fmt.Fprint(stderr, cliUsage)
+		return 2
+	}
+	passphrase := func() ([]byte, error) { return readPassphrase(stdin, stderr) }
+	kt := &KeyTable{keyList: map[string]KeyObj{}}
+	var key *KeyObj
+	for _, name := range keyFiles {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		key, err = parseKeyJSON(data)
+		if err != nil {
+			fmt.Fprintln(stderr, "invalid key:", err)
+			return 1
+		}
+		kt.keyList[key.Id] = *key
+	}
+
+	switch {
+	case args[0] == "sign" && len(files) == 1 && len(keyFiles) == 1:
+		pw, err := passphrase()
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		signer, err := NewSigner(key, pw)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		if *alg != "" {
+			if err := signer.SetAlgorithm(*alg); err != nil {
+				fmt.Fprintln(stderr, err)
+				return 2
+			}
+		}
+		sigPath, err := signFile(signer, files[0])
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		fmt.Fprintln(stdout, "signature written to", sigPath)
+	case args[0] == "verify" && len(files) <= 2:
+		sigPath := ""
+		if len(files) == 2 {
+			sigPath = files[1]
+		}
+		// only a single key is a sensible fallback for signatures without a fingerprint
+		var fallback *KeyObj
+		if len(keyFiles) == 1 {
+			fallback = key
+		}
+		sig, signer, err := verifyFile(kt, fallback, files[0], sigPath)
+		switch {
+		case errors.Is(err, ErrAuthFailed):
+			fmt.Fprintln(stderr, "BAD signature:", err)
+			return 1
+		case err != nil:
+			// unreadable files, malformed signatures and unknown signers say nothing about validity
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "good signature from %s made %s\n", keyDescription(signer), signatureTime(sig))
+	case args[0] == "encrypt" && len(files) == 1 && len(keyFiles) == 1:
+		var opts []cryptOption
+		if *hideRecipient {
+			opts = append(opts, withHiddenRecipient())
+		}
+		if err := encryptFile(key, files[0], opts...); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		fmt.Fprintln(stdout, "cryptogram written to", files[0]+".soap")
+	case args[0] == "decrypt" && len(files) == 1:
+		outPath := *output
+		if outPath == "" {
+			outPath = strings.TrimSuffix(files[0], ".soap")
+		}
+		if outPath == files[0] {
+			fmt.Fprintln(stderr, "no output file given for", files[0])
+			return 2
+		}
+		key, err := decryptFile(kt, files[0], outPath, passphrase)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		if key != nil {
+			fmt.Fprintf(stdout, "decrypted with key %s to %s\n", key.Id, outPath)
+		} else {
+			fmt.Fprintln(stdout, "decrypted to", outPath)
+		}
+	default:
+		fmt.Fprint(stderr, cliUsage)
+		return 2
+	}
+	return 0
+}
+
+/*
+Reads a passphrase from the first line of r. If r is a terminal the
+prompt is written to prompt and the passphrase is read with echo off.
+*/
+func readPassphrase(r io.Reader, prompt io.Writer) ([]byte, error) {
+	if f, ok := r.(*os.File); ok && isTerminal(f.Fd()) {
+		restore, err := disableEcho(f.Fd())
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprint(prompt, "passphrase: ")
+		defer fmt.Fprintln(prompt)
+		defer restore()
+	}
+	pw, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return []byte(strings.TrimRight(pw, "\r\n")), nil
+}
+
+// Encrypts the file at path to the public key of key, writing path.soap.
+func encryptFile(key *KeyObj, path string, opts ...cryptOption) error {
+	V, err := key.publicKey()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	cg, err := encryptWithKey(V, &data, opts...)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path+".soap", []byte(formatSOAP(*cg, soapMessageBegin, soapMessageEnd)+"\n"), 0644)
+}
+
+/*
+Decrypts the armored cryptogram at path with the stored private keys, or
+with the result of passphrase if none of them is the recipient. The
+plaintext is written byte for byte to a new file at outPath, which must
+not exist yet.
+
+	return: the stored key that decrypted the cryptogram, nil if the
+	passphrase did, or an error
+*/
+func decryptFile(kt *KeyTable, path, outPath string, passphrase func() ([]byte, error)) (*KeyObj, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	text := string(data)
+	raw, err := parseSOAP(&text, soapMessageBegin, soapMessageEnd)
+	if err != nil {
+		return nil, err
+	}
+	cg, err := decodeECCryptogram(raw)
+	if err != nil {
+		return nil, err
+	}
+	m, key, err := kt.decrypt(cg)
+	if errors.Is(err, errNoPrivateKey) {
+		pw, err := passphrase()
+		if err != nil {
+			return nil, err
+		}
+		m, err = decryptWithKey(pw, cg)
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	out, err := os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := out.Write(m); err != nil {
+		out.Close()
+		return nil, err
+	}
+	return key, out.Close()
+}
+
+// Collects the values of a repeated -key flag.
+type keyFileList []string
+
+func (l *keyFileList) String() string { return strings.Join(*l, ",") }
+
+func (l *keyFileList) Set(name string) error {
+	*l = append(*l, name)
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLISignAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "notes.txt")
+	keyFile := filepath.Join(dir, "key.SOAP_KEY")
+	os.WriteFile(file, []byte("meeting at noon"), 0644)
+	keyJSON, _ := KeyToJSON(testKeyObj(t, E521Curve, "secret", "cli-key"))
+	os.WriteFile(keyFile, keyJSON, 0600)
+
+	run := func(stdin string, args ...string) (int, string) {
+		var out bytes.Buffer
+		code := runCLI(args, strings.NewReader(stdin), &out, &out)
+		return code, out.String()
+	}
+	if code, out := run("wrong\n", "sign", "-key", keyFile, file); code != 1 {
+		t.Errorf("wrong passphrase: exit %d: %s", code, out)
+	}
+	if code, out := run("secret\n", "sign", "-key", keyFile, file); code != 0 {
+		t.Fatalf("sign: exit %d: %s", code, out)
+	}
+	otherFile := filepath.Join(dir, "other.SOAP_KEY")
+	otherJSON, _ := KeyToJSON(testKeyObj(t, Ed448Curve, "other", "other-key"))
+	os.WriteFile(otherFile, otherJSON, 0600)
+	if code, out := run("", "verify", "-key", otherFile, "-key", keyFile, file); code != 0 || !strings.Contains(out, "good signature from owner (key cli-key)") {
+		t.Errorf("verify: exit %d: %s", code, out)
+	}
+	if code, out := run("", "verify", "-key", otherFile, file); code != 1 || !strings.Contains(out, "unknown signer") {
+		t.Errorf("verify without the signer's key: exit %d: %s", code, out)
+	}
+	if code, out := run("secret\n", "sign", "-key", keyFile, "-key", otherFile, file); code != 2 {
+		t.Errorf("sign with two keys: exit %d: %s", code, out)
+	}
+	os.WriteFile(file, []byte("meeting at one"), 0644)
+	if code, out := run("", "verify", "-key", keyFile, file, file+".sig"); code != 1 || !strings.Contains(out, "BAD signature") {
+		t.Errorf("verify of modified file: exit %d: %s", code, out)
+	}
+	if code, out := run("", "verify", "-key", keyFile, file, file+".missing"); code != 1 || strings.Contains(out, "BAD signature") {
+		t.Errorf("verify with a missing signature file: exit %d: %s", code, out)
+	}
+	if code, out := run("secret\n", "sign", "-key", keyFile, "-alg", "Ed521", file); code != 0 {
+		t.Fatalf("sign with Ed521: exit %d: %s", code, out)
+	}
+	if code, out := run("", "verify", "-key", keyFile, file); code != 0 || !strings.Contains(out, "good signature") {
+		t.Errorf("verify of an Ed521 signature: exit %d: %s", code, out)
+	}
+	if code, out := run("other\n", "sign", "-key", otherFile, "-alg", "Ed521", file); code != 2 {
+		t.Errorf("Ed521 with an Ed448 key: exit %d: %s", code, out)
+	}
+	for _, args := range [][]string{{}, {"sign"}, {"frobnicate", "-key", keyFile, file}, {"verify", "-key", keyFile}} {
+		if code, _ := run("", args...); code != 2 {
+			t.Errorf("%q: exit %d, want 2", args, code)
+		}
+	}
+}
+
+func TestCLIDispatch(t *testing.T) {
+	for _, args := range [][]string{{"sign", "x"}, {"verify"}, {"encrypt"}, {"decrypt", "x.soap"}} {
+		if !isCLICommand(args) {
+			t.Errorf("%q did not start the command line interface", args)
+		}
+	}
+	for _, args := range [][]string{{}, {"--display", ":1"}, {"--sync"}, {"notes.txt"}} {
+		if isCLICommand(args) {
+			t.Errorf("%q did not start the graphical interface", args)
+		}
+	}
+}
+
+func TestCLIEncryptAndDecrypt(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "image.png")
+	png := append([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), 0xff, 0x00, 0xfe, '\n', '\r')
+	os.WriteFile(file, png, 0644)
+	key := testKeyObj(t, E521Curve, "secret", "cli-key")
+	keyFile, pubFile := filepath.Join(dir, "key.SOAP_KEY"), filepath.Join(dir, "pub.SOAP_KEY")
+	keyJSON, _ := KeyToJSON(key)
+	os.WriteFile(keyFile, keyJSON, 0600)
+	pubJSON, _ := KeyToJSON(&KeyObj{Id: key.Id, Owner: key.Owner, KeyType: "PUBLIC", PubKey: key.PubKey})
+	os.WriteFile(pubFile, pubJSON, 0644)
+
+	run := func(stdin string, args ...string) (int, string) {
+		var out bytes.Buffer
+		code := runCLI(args, strings.NewReader(stdin), &out, &out)
+		return code, out.String()
+	}
+	if code, out := run("", "encrypt", "-key", pubFile, file); code != 0 {
+		t.Fatalf("encrypt: exit %d: %s", code, out)
+	}
+	armored, _ := os.ReadFile(file + ".soap")
+	if !strings.HasPrefix(string(armored), soapMessageBegin) {
+		t.Fatalf("cryptogram is not armored: %q", armored)
+	}
+	os.Remove(file)
+	if code, out := run("", "decrypt", "-key", keyFile, file+".soap"); code != 0 || !strings.Contains(out, "key cli-key") {
+		t.Fatalf("decrypt with the stored key: exit %d: %s", code, out)
+	}
+	if got, _ := os.ReadFile(file); !bytes.Equal(got, png) {
+		t.Errorf("binary plaintext did not round trip: %q", got)
+	}
+	if code, out := run("", "decrypt", "-key", keyFile, file+".soap"); code != 1 {
+		t.Errorf("decrypt over an existing file: exit %d: %s", code, out)
+	}
+
+	// a hidden recipient, decrypted from the passphrase without a stored key
+	if code, out := run("", "encrypt", "-key", pubFile, "-hide-recipient", file); code != 0 {
+		t.Fatalf("encrypt: exit %d: %s", code, out)
+	}
+	out := filepath.Join(dir, "out.bin")
+	if code, msg := run("wrong\n", "decrypt", "-o", out, file+".soap"); code != 1 || !strings.Contains(msg, ErrAuthFailed.Error()) {
+		t.Errorf("wrong passphrase: exit %d: %s", code, msg)
+	}
+	if code, msg := run("secret\n", "decrypt", "-o", out, file+".soap"); code != 0 {
+		t.Fatalf("decrypt with the passphrase: exit %d: %s", code, msg)
+	}
+	if got, _ := os.ReadFile(out); !bytes.Equal(got, png) {
+		t.Errorf("binary plaintext did not round trip: %q", got)
+	}
+	for _, args := range [][]string{{"encrypt", file}, {"encrypt", "-key", keyFile, "-key", pubFile, file}, {"decrypt", file}} {
+		if code, _ := run("", args...); code != 2 {
+			t.Errorf("%q: exit %d, want 2", args, code)
+		}
+	}
+}
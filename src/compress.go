@@ -0,0 +1,60 @@
+package main
+
+/*
+Optional DEFLATE compression of plaintexts prior to encryption. Compression
+runs before padding so that padding also hides the compressed length.
+
+SECURITY NOTE: compressing secrets together with attacker-influenced data
+leaks information through the ciphertext length (CRIME/BREACH). Callers
+encrypting such data should pass withoutCompression.
+*/
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"math"
+)
+
+// plaintext compressed with DEFLATE, recorded in the F field of a cryptogram
+const flagDeflate byte = 1 << 2
+
+// default upper bound on the size of a decompressed plaintext
+const maxDecompressedSize = 64 << 20
+
+// Compresses m with DEFLATE at the default compression level.
+func compressMessage(m []byte) []byte {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	w.Write(m)
+	w.Close()
+	return buf.Bytes()
+}
+
+/*
+Inflates a DEFLATE stream, refusing to produce more than limit bytes
+so that a small cryptogram cannot expand into an arbitrarily large one.
+
+	c: compressed message
+	limit: maximum permitted size of the decompressed message, >= 0
+	return: decompressed message, or an error wrapping ErrMalformed if
+	        malformed or too large
+*/
+func decompressMessage(c []byte, limit int64) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(c))
+	defer r.Close()
+	// read one byte past the limit to detect a larger message, without overflowing
+	n := limit
+	if n < math.MaxInt64 {
+		n++
+	}
+	m, err := io.ReadAll(io.LimitReader(r, n))
+	if err != nil {
+		return nil, wrapErr(ErrMalformed, errors.New("invalid compressed data"))
+	}
+	if int64(len(m)) > limit {
+		return nil, wrapErr(ErrMalformed, errors.New("decompressed message exceeds size limit"))
+	}
+	return m, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestDecompressionLimit(t *testing.T) {
+	m := make([]byte, 4096)
+	c := compressMessage(m)
+	if got, err := decompressMessage(c, int64(len(m))); err != nil || !bytes.Equal(got, m) {
+		t.Fatalf("message at the limit rejected: %v", err)
+	}
+	if _, err := decompressMessage(c, int64(len(m)-1)); !errors.Is(err, ErrMalformed) {
+		t.Errorf("message one byte over the limit gave %v, want ErrMalformed", err)
+	}
+	if _, err := decompressMessage([]byte("not deflate"), int64(len(m))); !errors.Is(err, ErrMalformed) {
+		t.Errorf("invalid compressed data gave %v, want ErrMalformed", err)
+	}
+	if got, err := decompressMessage(c, math.MaxInt64); err != nil || !bytes.Equal(got, m) {
+		t.Errorf("message under a MaxInt64 limit: %d bytes, %v", len(got), err)
+	}
+}
+
+func TestMaxDecompressedSizeOption(t *testing.T) {
+	m := bytes.Repeat([]byte("compressible "), 100)
+	raw, _ := encryptWithPW([]byte("pw"), &m, withCompression(true))
+	cg, _ := decodeSymCryptogram(raw)
+	for name, limit := range map[string]int64{"MaxInt64": math.MaxInt64, "negative": -1, "MinInt64": math.MinInt64} {
+		if got, err := decryptWithPW([]byte("pw"), cg, withMaxDecompressedSize(limit)); err != nil || !bytes.Equal(*got, m) {
+			t.Errorf("%s limit: plaintext not recovered: %v", name, err)
+		}
+	}
+	if cfg := newCryptConfig([]cryptOption{withMaxDecompressedSize(-1)}); cfg.maxInflate != maxDecompressedSize {
+		t.Errorf("negative limit replaced the default with %d", cfg.maxInflate)
+	}
+	if got, err := decryptWithPW([]byte("pw"), cg, withMaxDecompressedSize(0)); !errors.Is(err, ErrMalformed) || got != nil {
+		t.Errorf("zero limit: got %v, want ErrMalformed", err)
+	}
+}
+
+func TestZipBombRejected(t *testing.T) {
+	// 16 MiB of zeros compresses to a few kilobytes
+	bomb := make([]byte, 16<<20)
+	raw, err := encryptWithPW([]byte("pw"), &bomb, withCompression(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*raw) > 64<<10 {
+		t.Fatalf("cryptogram of %d bytes is not a zip bomb", len(*raw))
+	}
+	cg, _ := decodeSymCryptogram(raw)
+	if m, err := decryptWithPW([]byte("pw"), cg, withMaxDecompressedSize(1<<20)); !errors.Is(err, ErrMalformed) || m != nil {
+		t.Errorf("got %v, want ErrMalformed and no plaintext", err)
+	}
+	if m, err := decryptWithPW([]byte("pw"), cg); err != nil || len(*m) != len(bomb) {
+		t.Errorf("cryptogram within the default limit rejected: %v", err)
+	}
+}
+
+func TestWithoutCompression(t *testing.T) {
+	m := bytes.Repeat([]byte("compressible "), 100)
+	for name, opts := range map[string][]cryptOption{
+		"after withCompression":  {withCompression(true), withoutCompression()},
+		"before withCompression": {withoutCompression(), withCompression(true)},
+	} {
+		if cfg := newCryptConfig(opts); cfg.compress || cfg.flags()&flagDeflate != 0 {
+			t.Errorf("%s: compression still enabled", name)
+		}
+		raw, err := encryptWithPW([]byte("pw"), &m, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cg, _ := decodeSymCryptogram(raw)
+		if cg.F&flagDeflate != 0 || len(cg.C) != len(m) {
+			t.Errorf("%s: plaintext was compressed", name)
+		}
+	}
+	raw, _ := encryptWithPW([]byte("pw"), &m, withCompression(true))
+	if cg, _ := decodeSymCryptogram(raw); cg.F&flagDeflate == 0 || len(cg.C) >= len(m) {
+		t.Error("withCompression did not compress")
+	}
+}
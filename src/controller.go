@@ -4,8 +4,11 @@ package main
 transmits messages to view from model. */
 
 import (
+	"crypto/rand"
 	"encoding/hex"
-	"math/big"
+	"errors"
+	"fmt"
+	"os"
 
 	"github.com/gotk3/gotk3/gtk"
 )
@@ -87,7 +90,12 @@ func setSymEncrypt(ctx *WindowCtx) {
 		text, _ := ctx.notePad.GetText(ctx.notePad.GetStartIter(), ctx.notePad.GetEndIter(), false)
 		textBytes := []byte(text)
 		ctx.toggleButtons(ctx.buttons, false)
-		cg := encryptWithPW([]byte(password), &textBytes)
+		cg, err := encryptWithPW([]byte(password), &textBytes, withPadding(ctx.padding), withCompression(ctx.compress))
+		if err != nil {
+			ctx.toggleButtons(ctx.buttons, true)
+			ctx.updateStatus("encryption failed")
+			return
+		}
 		temp := hex.EncodeToString(*cg)
 		res := getSOAP(&temp, ctx, soapMessageBegin, soapMessageEnd)
 		ctx.notePad.SetText(*res)
@@ -109,11 +117,12 @@ func setSymDecrypt(ctx *WindowCtx) {
 		psdMsg, err1 := parseSOAP(&notePadText, soapMessageBegin, soapMessageEnd)
 		if err1 != nil {
 			ctx.updateStatus("unable to decrypt")
+		} else if cg, err := decodeSymCryptogram(psdMsg); err != nil {
+			ctx.updateStatus(decryptionStatus(err))
 		} else {
-			cg, _ := decodeSymCryptogram(psdMsg)
 			dec, err := decryptWithPW([]byte(password), cg)
 			if err != nil {
-				ctx.updateStatus(err.Error())
+				ctx.updateStatus(decryptionStatus(err))
 			} else {
 				ctx.notePad.SetText(string(*dec))
 				ctx.updateStatus("decryption successful")
@@ -126,11 +135,11 @@ func setSymDecrypt(ctx *WindowCtx) {
 
 // Connects keypari generation to button
 func setKeyPair(ctx *WindowCtx) {
-	(*ctx.buttons)[4].SetTooltipMarkup("Generates a Schnorr E521 keypair from supplied password.")
+	(*ctx.buttons)[4].SetTooltipMarkup("Generates a Schnorr keypair on the selected curve from supplied password.")
 	ctx.initialState = false
 	ctx.fileMode = false
 	key := KeyObj{}
-	opResult := constructKey(ctx, &key)
+	opResult := constructKey(ctx, &key, rand.Reader)
 	if opResult {
 		ctx.keytable.importKey(ctx, key)
 		ctx.updateStatus("key " + key.Id + " generated successfully")
@@ -143,17 +152,31 @@ func setKeyPair(ctx *WindowCtx) {
 func setEcEncrypt(ctx *WindowCtx) {
 	(*ctx.buttons)[5].SetTooltipMarkup("Encrypts data using a public key selected from the key table.")
 	ctx.initialState = false
-	ctx.fileMode = false
 	if ctx.loadedKey != nil {
-		text, _ := ctx.notePad.GetText(ctx.notePad.GetStartIter(), ctx.notePad.GetEndIter(), false)
-		textBytes := []byte(text)
-		ctx.toggleButtons(ctx.buttons, false)
+		textBytes, err := ctx.inputBytes()
+		if err != nil {
+			ctx.updateStatus("unable to read " + ctx.loadedFile.Name())
+			return
+		}
 		//construct the key
-		pubX, _ := big.NewInt(0).SetString(ctx.loadedKey.PubKeyX, 10)
-		pubY, _ := big.NewInt(0).SetString(ctx.loadedKey.PubKeyY, 10)
-		key := NewE521XY(*pubX, *pubY)
+		key, err := ctx.loadedKey.publicKey()
+		if err != nil {
+			ctx.updateStatus("invalid public key")
+			return
+		}
+		ctx.toggleButtons(ctx.buttons, false)
 
-		result := hex.EncodeToString(*encryptWithKey(key, &textBytes))
+		opts := []cryptOption{withPadding(ctx.padding), withCompression(ctx.compress)}
+		if ctx.hideRecipient {
+			opts = append(opts, withHiddenRecipient())
+		}
+		cg, err := encryptWithKey(key, &textBytes, opts...)
+		if err != nil {
+			ctx.toggleButtons(ctx.buttons, true)
+			ctx.updateStatus("encryption failed")
+			return
+		}
+		result := hex.EncodeToString(*cg)
 		res := getSOAP(&result, ctx, soapMessageBegin, soapMessageEnd)
 		ctx.notePad.SetText(*res)
 
@@ -164,37 +187,73 @@ func setEcEncrypt(ctx *WindowCtx) {
 	}
 }
 
-// EC decryption. TODO Searches keytable for corresponding private key.
+/*
+EC decryption. Searches the key table for the private key named by the
+cryptogram, or tries every private key if the recipient is hidden. Falls
+back to deriving the private key from a passphrase if no stored key works.
+*/
 func setEcDecrypt(ctx *WindowCtx) {
-	(*ctx.buttons)[6].SetTooltipMarkup("Decrypts data using passphrase that corresponds to a valid private key.")
+	(*ctx.buttons)[6].SetTooltipMarkup("Decrypts data using a stored private key, or a passphrase that corresponds to a valid private key.")
 	ctx.initialState = false
 	ctx.fileMode = false
-	password, result := passwordEntryDialog(ctx.win, "decryption")
-	if password != "" && result {
-		text, _ := ctx.notePad.GetText(ctx.notePad.GetStartIter(), ctx.notePad.GetEndIter(), true)
-		text2, err := parseSOAP(&text, soapMessageBegin, soapMessageEnd)
-		if err != nil {
-			ctx.updateStatus(err.Error())
-		} else {
-			psdMsg, err := decodeECCryptogram(text2)
-			if err != nil {
-				ctx.updateStatus(err.Error())
-			} else {
-				message, err := decryptWithKey([]byte(password), psdMsg)
-				if err != nil {
-					ctx.updateStatus(err.Error())
-				} else {
-					ctx.notePad.SetText(*message)
-					ctx.updateStatus("decryption successful")
-				}
-			}
+	text, _ := ctx.notePad.GetText(ctx.notePad.GetStartIter(), ctx.notePad.GetEndIter(), true)
+	text2, err := parseSOAP(&text, soapMessageBegin, soapMessageEnd)
+	if err != nil {
+		ctx.updateStatus(err.Error())
+		return
+	}
+	psdMsg, err := decodeECCryptogram(text2)
+	if err != nil {
+		ctx.updateStatus(decryptionStatus(err))
+		return
+	}
+	message, key, err := ctx.keytable.decrypt(psdMsg)
+	if errors.Is(err, errNoPrivateKey) {
+		password, result := passwordEntryDialog(ctx.win, "decryption")
+		if password == "" || !result {
+			ctx.updateStatus("decryption cancelled")
+			return
 		}
+		message, err = decryptWithKey([]byte(password), psdMsg)
+	}
+	if err != nil {
+		ctx.updateStatus(decryptionStatus(err))
+	} else if key != nil {
+		showResult(ctx, message, "decrypted with key "+key.Id)
 	} else {
-		ctx.updateStatus("decryption cancelled")
+		showResult(ctx, message, "decryption successful")
 	}
 }
 
-// Signs a message using a private key derived from a password.
+/*
+Displays a binary-safe result in the notepad if it is readable UTF-8 text.
+Anything else, such as an image or archive, would be corrupted by the text
+buffer and is instead written to a file chosen by the user.
+*/
+func showResult(ctx *WindowCtx, data []byte, status string) {
+	if isDisplayableText(data) {
+		ctx.notePad.SetText(string(data))
+		ctx.updateStatus(status)
+		return
+	}
+	filename, ok := saveResultDialog(ctx)
+	if !ok {
+		ctx.updateStatus("binary result discarded")
+		return
+	}
+	if err := os.WriteFile(filename, data, 0600); err != nil {
+		ctx.updateStatus("failed to write " + filename)
+		return
+	}
+	ctx.notePad.SetText("Binary result saved to " + filename)
+	ctx.updateStatus(status)
+}
+
+/*
+Signs a message using a private key derived from a password. The message is
+clear-signed if that option is set, and otherwise replaced by an opaque
+signature that embeds it.
+*/
 func setEcSignature(ctx *WindowCtx) {
 	(*ctx.buttons)[7].SetTooltipMarkup("Signs a message with a selected key.")
 	ctx.initialState = false
@@ -202,8 +261,18 @@ func setEcSignature(ctx *WindowCtx) {
 	password, result := passwordEntryDialog(ctx.win, "signature")
 	if result {
 		text, _ := ctx.notePad.GetText(ctx.notePad.GetStartIter(), ctx.notePad.GetEndIter(), true)
+		if ctx.clearSign {
+			signed, err := clearSign(ctx.signingCurve(), []byte(password), text)
+			if err != nil {
+				ctx.updateStatus(err.Error())
+				return
+			}
+			ctx.notePad.SetText(signed)
+			ctx.updateStatus("signature generated")
+			return
+		}
 		textBytes := []byte(text)
-		signature, err := signWithKey([]byte(password), &textBytes)
+		signature, err := signWithKeyOn(ctx.signingCurve(), []byte(password), &textBytes)
 		if err != nil {
 			ctx.updateStatus(err.Error())
 		} else {
@@ -217,34 +286,121 @@ func setEcSignature(ctx *WindowCtx) {
 	}
 }
 
-// Verifies signature using public key.
+/*
+Verifies the signature or clear-signed message in the notepad. The verifying
+key is found in the key table by the signer fingerprint the signature
+carries; signatures made before fingerprints were recorded are checked
+against the selected key.
+*/
 func setEcVerify(ctx *WindowCtx) {
-	(*ctx.buttons)[8].SetTooltipMarkup("Verifies a signature against a public key.")
+	(*ctx.buttons)[8].SetTooltipMarkup("Verifies a signature against the public key of its signer, found among the imported keys.")
 	ctx.initialState = false
 	ctx.fileMode = false
 	text, _ := ctx.notePad.GetText(ctx.notePad.GetStartIter(), ctx.notePad.GetEndIter(), true)
-	if ctx.loadedKey != nil {
-		pubKeyObj := ctx.loadedKey                                //loaded key should maybe be keyoobj with E521 for public key instead of x/y
-		keyX, _ := big.NewInt(0).SetString(pubKeyObj.PubKeyX, 10) //refactor
-		keyY, _ := big.NewInt(0).SetString(pubKeyObj.PubKeyY, 10) //refactor
-		key := NewE521XY(*keyX, *keyY)
-		signatureBytes, err := parseSOAP(&text, signatureBegin, signatureEnd)
-		if err != nil {
-			ctx.updateStatus("error parsing signature")
-		} else {
-			signature, err2 := decodeSignature(signatureBytes)
-			if err != nil || err2 != nil {
-				ctx.updateStatus("unable to parse signature")
-			} else {
-				result := verify(key, signature, &signature.M)
-				if result {
-					ctx.updateStatus("good signature from key " + ctx.loadedKey.Id)
-				} else {
-					ctx.updateStatus("unable to verify signature")
-				}
-			}
-		}
+	signatureBytes, err := parseSOAP(&text, signatureBegin, signatureEnd)
+	if err != nil {
+		ctx.updateStatus("error parsing signature")
+		return
+	}
+	signature, err := decodeSignature(signatureBytes)
+	if err != nil {
+		ctx.updateStatus("unable to parse signature")
+		return
+	}
+	keyObj, err := ctx.keytable.signerKey(signature.F, ctx.loadedKey)
+	if err != nil {
+		ctx.updateStatus(signerStatus(signature.F))
+		return
+	}
+	key, err := keyObj.publicKey()
+	if err != nil {
+		ctx.updateStatus("invalid public key")
+		return
+	}
+	if verifyMessage(key, signature, &signature.M) {
+		ctx.updateStatus("good signature from " + keyDescription(keyObj))
 	} else {
+		ctx.updateStatus("unable to verify signature")
+	}
+}
+
+// Names a key in status messages by its owner and Id.
+func keyDescription(key *KeyObj) string {
+	return key.Owner + " (key " + key.Id + ")"
+}
+
+// Status for a signature whose verifying key could not be selected.
+func signerStatus(fp []byte) string {
+	if len(fp) == 0 {
+		return "no key selected"
+	}
+	return fmt.Sprintf("unknown signer %x", fp)
+}
+
+// Signs a chosen file with the selected key, writing a detached signature next to it.
+func signFileWithKey(ctx *WindowCtx) {
+	if ctx.loadedKey == nil {
 		ctx.updateStatus("no key selected")
+		return
+	}
+	path, ok := openFileDialog(ctx, "Sign File")
+	if !ok {
+		ctx.updateStatus("signature cancelled")
+		return
+	}
+	password, result := passwordEntryDialog(ctx.win, "signature")
+	if !result {
+		ctx.updateStatus("signature cancelled")
+		return
+	}
+	signer, err := NewSigner(ctx.loadedKey, []byte(password))
+	if errors.Is(err, ErrAuthFailed) {
+		ctx.updateStatus("passphrase does not match the selected key")
+		return
+	} else if err != nil {
+		ctx.updateStatus("invalid key: " + err.Error())
+		return
+	}
+	sigPath, err := signFile(signer, path)
+	if err != nil {
+		ctx.updateStatus("unable to sign file: " + err.Error())
+		return
+	}
+	ctx.updateStatus("signature written to " + sigPath)
+}
+
+// Verifies a chosen file, or the file belonging to a chosen .sig file, against its detached signature.
+func verifyFileWithKey(ctx *WindowCtx) {
+	path, ok := openFileDialog(ctx, "Verify File")
+	if !ok {
+		ctx.updateStatus("verification cancelled")
+		return
+	}
+	path = signedFilePath(path)
+	sig, key, err := verifyFile(ctx.keytable, ctx.loadedKey, path, "")
+	switch {
+	case errors.Is(err, errUnknownSigner):
+		ctx.updateStatus(signerStatus(sig.Signer))
+	case errors.Is(err, ErrAuthFailed):
+		ctx.updateStatus("BAD signature on " + path)
+	case err != nil:
+		ctx.updateStatus("unable to verify signature: " + err.Error())
+	default:
+		ctx.updateStatus("good signature from " + keyDescription(key) + " made " + signatureTime(sig))
+	}
+}
+
+// Maps model errors to status messages. Authentication failures stay
+// ambiguous so the status does not reveal why a password was rejected.
+func decryptionStatus(err error) string {
+	switch {
+	case errors.Is(err, ErrAuthFailed):
+		return "unable to decrypt"
+	case errors.Is(err, ErrUnsupportedVersion):
+		return "unsupported message format"
+	case errors.Is(err, ErrInvalidPoint):
+		return "invalid message nonce"
+	default:
+		return "malformed message"
 	}
 }
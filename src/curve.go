@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+/*
+Curve and Point abstract the group operations used by key generation,
+ECDHIES encryption and Schnorr signatures, so that the schemes in model.go
+can run over any prime order subgroup of an Edwards curve.
+
+Points of different curves must never be mixed; the Point methods panic
+when handed a point of another curve.
+*/
+type Curve interface {
+	ID() byte     // identifier recorded in keys, cryptograms and signatures
+	Name() string // human readable name, also used in exported keys
+	Generator() Point
+	Order() *big.Int // prime order of the generator
+	// multiplies the generator by a secret scalar
+	ScalarBaseMult(k *big.Int) Point
+	// decodes the canonical compressed encoding of a point on this curve
+	DecodePoint(data []byte) (Point, error)
+	// variable time sum of scalars[i]*points[i], for public inputs only
+	MultiScalarMul(points []Point, scalars []*big.Int) Point
+	// field of scalars modulo Order
+	scalars() *scalarField
+}
+
+type Point interface {
+	Curve() Curve
+	AddPoint(q Point) Point
+	// multiplies the point by a secret scalar
+	ScalarMult(k *big.Int) Point
+	Equal(q Point) bool
+	IsIdentity() bool
+	// canonical compressed encoding
+	MarshalBinary() ([]byte, error)
+	// x || y, each big-endian, used for key fingerprints
+	MarshalUncompressed() []byte
+	// affine x coordinate in [0, p), from which shared secrets and hashes are derived
+	AffineX() *big.Int
+	// rejects points unfit for use with a secret scalar, see E521.validate
+	validate() error
+}
+
+// Curve identifiers. E521 is zero so that keys, cryptograms and
+// signatures written before the curve was recorded keep their meaning.
+const (
+	CurveE521  byte = 0
+	CurveEd448 byte = 1
+)
+
+// Every supported curve, indexed by identifier.
+var supportedCurves = []Curve{E521Curve, Ed448Curve}
+
+// Looks up a curve by the identifier recorded in cryptograms and signatures.
+func curveByID(id byte) (Curve, error) {
+	if int(id) >= len(supportedCurves) {
+		return nil, fmt.Errorf("%w: unknown curve %d", ErrUnsupportedVersion, id)
+	}
+	return supportedCurves[id], nil
+}
+
+// Looks up a curve by the name recorded in keys. An empty name is E521.
+func curveByName(name string) (Curve, error) {
+	if name == "" {
+		return E521Curve, nil
+	}
+	for _, c := range supportedCurves {
+		if c.Name() == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown curve %q", ErrUnsupportedVersion, name)
+}
+
+// The E521 curve, implemented by E521.go and friends.
+var E521Curve Curve = e521Curve{}
+
+type e521Curve struct{}
+
+func (e521Curve) ID() byte                        { return CurveE521 }
+func (e521Curve) Name() string                    { return "E521" }
+func (e521Curve) Generator() Point                { return E521GenPoint(0) }
+func (e521Curve) Order() *big.Int                 { return new(big.Int).Set(&scalarOrder) }
+func (e521Curve) ScalarBaseMult(k *big.Int) Point { return E521GenMul(k) }
+func (e521Curve) scalars() *scalarField           { return e521Scalars }
+
+func (e521Curve) DecodePoint(data []byte) (Point, error) {
+	if len(data) != E521CompressedSize {
+		return nil, wrapErr(ErrMalformed, errors.New("invalid point encoding length"))
+	}
+	P := new(E521)
+	if err := P.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return P, nil
+}
+
+func (e521Curve) MultiScalarMul(points []Point, scalars []*big.Int) Point {
+	ps := make([]*E521, len(points))
+	for i, P := range points {
+		ps[i] = asE521(P)
+	}
+	return MultiScalarMul(ps, scalars)
+}
+
+// Converts a Point known to lie on E521, panicking otherwise.
+func asE521(P Point) *E521 {
+	e, ok := P.(*E521)
+	if !ok {
+		panic("E521: point of another curve")
+	}
+	return e
+}
+
+func (e *E521) Curve() Curve                { return E521Curve }
+func (e *E521) AddPoint(q Point) Point      { return e.Add(asE521(q)) }
+func (e *E521) ScalarMult(k *big.Int) Point { return e.SecMul(k) }
+func (e *E521) AffineX() *big.Int           { x, _ := e.affine(); return x }
+
+// Reports whether q is the same point; points of other curves are never equal.
+func (e *E521) Equal(q Point) bool {
+	other, ok := q.(*E521)
+	return ok && e.Equals(other)
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+/*
+A detached signature over content kept elsewhere, typically a file with the
+signature stored next to it as <file>.sig. Only (h, z) and a little metadata
+are stored. The content is hashed as a stream, so files of any size can be
+signed without loading them into memory or into the notepad.
+
+The Schnorr signature of signWithKey is computed over
+
+	encode_string(Algorithm) || encode_string(KeyID) ||
+	encode_string(Created) || cSHAKE256(content, 512, “”, “SOAP-DETACHED”)
+
+so the metadata cannot be changed without invalidating the signature. Its
+challenge h is computed under “SOAP-DETACHED-SIG” instead of “T” (see
+sigChallenge), so a detached signature is never a valid message signature
+on the signed data, nor a message signature a valid detached one. The
+signer fingerprint is not covered: it only selects the key to verify with,
+and a wrong one makes verification fail.
+
+E521 keys may instead sign with Ed521 (see ed521.go), for verifiers that
+implement RFC 8032 style EdDSA. The same data is then signed as the message
+M with the context “SOAP-DETACHED”, the public key A is the Ed521 encoding
+of the key's V, and R || S is stored in place of (h, z).
+*/
+type DetachedSignature struct {
+	Algorithm string   // signature scheme, see detachedAlgorithm and ed521Algorithm
+	KeyID     string   // Id of the signing key
+	Signer    []byte   // fingerprint of the signing key, used to find the verifying key
+	Created   int64    // signing time in seconds since the Unix epoch
+	H         *big.Int // keyed hash of the signed data
+	Z         *big.Int // signature scalar
+	RS        []byte   // R || S of an Ed521 signature, which has no H and Z
+}
+
+// Customization string of the content digest.
+const detachedDigestCustomization = "SOAP-DETACHED"
+
+// Suffix of detached signature files.
+const detachedSuffix = ".sig"
+
+// Name of the Ed521 detached signature algorithm, which needs an E521 key.
+const ed521Algorithm = "Ed521"
+
+// Name of the signature scheme on curve, e.g. E521-Schnorr-cSHAKE256.
+func detachedAlgorithm(curve Curve) string { return curve.Name() + "-Schnorr-cSHAKE256" }
+
+// Returns the curve of a detached signature algorithm, or an error wrapping ErrUnsupportedVersion.
+func curveOfAlgorithm(alg string) (Curve, error) {
+	if alg == ed521Algorithm {
+		return E521Curve, nil
+	}
+	for _, curve := range supportedCurves {
+		if detachedAlgorithm(curve) == alg {
+			return curve, nil
+		}
+	}
+	return nil, wrapErr(ErrUnsupportedVersion, errors.New("unknown signature algorithm "+alg))
+}
+
+// Hashes content read from r, without holding it in memory.
+func contentDigest(r io.Reader) ([]byte, error) {
+	k := newCSHAKE256("", detachedDigestCustomization)
+	if _, err := io.Copy(k, r); err != nil {
+		return nil, err
+	}
+	return k.Sum(512), nil
+}
+
+// The data covered by the Schnorr signature: the metadata and the content digest.
+func (sig *DetachedSignature) signedData(digest []byte) []byte {
+	created := make([]byte, 8)
+	binary.BigEndian.PutUint64(created, uint64(sig.Created))
+	var data []byte
+	data = append(data, encodeString([]byte(sig.Algorithm))...)
+	data = append(data, encodeString([]byte(sig.KeyID))...)
+	data = append(data, encodeString(created)...)
+	return append(data, digest...)
+}
+
+// Signs content as the holder of a key.
+type Signer struct {
+	curve Curve
+	s     *Scalar
+	keyID string
+	fp    []byte
+	alg   string // detached signature algorithm, the Schnorr scheme of the curve by default
+}
+
+/*
+Derives the private scalar of key from passphrase pw. Returns an error
+wrapping ErrAuthFailed if pw does not belong to key.
+*/
+func NewSigner(key *KeyObj, pw []byte) (*Signer, error) {
+	V, err := key.publicKey()
+	if err != nil {
+		return nil, err
+	}
+	curve := V.Curve()
+	s := privateScalar(curve, pw)
+	if !curve.ScalarBaseMult(s.BigInt()).Equal(V) {
+		return nil, wrapErr(ErrAuthFailed, errors.New("passphrase does not match key "+key.Id))
+	}
+	return &Signer{curve: curve, s: s, keyID: key.Id, fp: keyFingerprint(V), alg: detachedAlgorithm(curve)}, nil
+}
+
+/*
+Selects the algorithm of the detached signatures s makes. Returns an error
+wrapping ErrUnsupportedVersion if alg is unknown or needs another curve.
+*/
+func (s *Signer) SetAlgorithm(alg string) error {
+	curve, err := curveOfAlgorithm(alg)
+	if err != nil {
+		return err
+	}
+	if curve != s.curve {
+		return wrapErr(ErrUnsupportedVersion, errors.New(alg+" needs a "+curve.Name()+" key"))
+	}
+	s.alg = alg
+	return nil
+}
+
+// Options of the Ed521 signatures in detached signatures.
+var ed521DetachedOptions = &Ed521Options{Context: detachedDigestCustomization}
+
+// Signs the content read from r, reading it once.
+func (s *Signer) Sign(r io.Reader) (*DetachedSignature, error) {
+	digest, err := contentDigest(r)
+	if err != nil {
+		return nil, err
+	}
+	sig := &DetachedSignature{
+		Algorithm: s.alg,
+		KeyID:     s.keyID,
+		Signer:    s.fp,
+		Created:   time.Now().Unix(),
+	}
+	data := sig.signedData(digest)
+	if s.alg == ed521Algorithm {
+		dom, _ := ed521DetachedOptions.dom()
+		prefix := ed521Prefix(s.s)
+		defer zeroize(prefix)
+		pub := ed521EncodePoint(E521GenMul(s.s.BigInt()))
+		sig.RS = ed521SignExpanded(s.s, prefix, pub, data, dom)
+		return sig, nil
+	}
+	schnorr := schnorrSign(s.curve, s.s, data, sigVersionDetached)
+	sig.H, sig.Z = schnorr.H, schnorr.Z
+	return sig, nil
+}
+
+/*
+Verifies a detached signature over the content read from r under the
+public key of key. Returns nil if the signature is valid, an error
+wrapping ErrAuthFailed if it is not, or ErrUnsupportedVersion if it was
+made with an algorithm other than the key's.
+*/
+func (key *KeyObj) Verify(r io.Reader, sig *DetachedSignature) error {
+	V, err := key.publicKey()
+	if err != nil {
+		return err
+	}
+	curve, err := curveOfAlgorithm(sig.Algorithm)
+	if err != nil {
+		return err
+	}
+	if curve != V.Curve() {
+		return wrapErr(ErrAuthFailed, errors.New("signature was made with a "+curve.Name()+" key"))
+	}
+	digest, err := contentDigest(r)
+	if err != nil {
+		return err
+	}
+	data := sig.signedData(digest)
+	if sig.Algorithm == ed521Algorithm {
+		return Ed521Verify(ed521EncodePoint(asE521(V)), data, sig.RS, ed521DetachedOptions)
+	}
+	if !verifyVersion(V, &Signature{H: sig.H, Z: sig.Z, C: curve.ID(), V: sigVersionDetached}, data, sigVersionDetached) {
+		return ErrAuthFailed
+	}
+	return nil
+}
+
+// Encodes a detached signature in SOAP armor.
+func encodeDetachedSignature(sig *DetachedSignature) (string, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(sig); err != nil {
+		return "", errors.New("failed to encode signature")
+	}
+	return formatSOAP(buf.Bytes(), detachedBegin, detachedEnd), nil
+}
+
+/*
+Parses an armored detached signature, rejecting unknown algorithms,
+non-canonical Schnorr scalars and Ed521 signatures of the wrong length.
+*/
+func decodeDetachedSignature(text string) (*DetachedSignature, error) {
+	data, err := parseSOAP(&text, detachedBegin, detachedEnd)
+	if err != nil {
+		return nil, err
+	}
+	var sig DetachedSignature
+	if err := gob.NewDecoder(bytes.NewReader(*data)).Decode(&sig); err != nil {
+		return nil, wrapErr(ErrMalformed, err)
+	}
+	curve, err := curveOfAlgorithm(sig.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if sig.Algorithm == ed521Algorithm {
+		if sig.H != nil || sig.Z != nil || len(sig.RS) != Ed521SignatureSize {
+			return nil, wrapErr(ErrMalformed, errors.New("invalid Ed521 signature"))
+		}
+	} else if sig.RS != nil {
+		return nil, wrapErr(ErrMalformed, errors.New("Schnorr signature with an Ed521 field"))
+	} else if err := (&Signature{H: sig.H, Z: sig.Z, C: curve.ID(), V: sigVersionDetached}).checkForm(curve); err != nil {
+		return nil, err
+	}
+	if len(sig.Signer) != 0 && len(sig.Signer) != fingerprintSize {
+		return nil, wrapErr(ErrMalformed, errors.New("invalid signer fingerprint"))
+	}
+	return &sig, nil
+}
+
+// Signs the file at path and writes the signature to path + ".sig".
+func signFile(s *Signer, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sig, err := s.Sign(f)
+	if err != nil {
+		return "", err
+	}
+	text, err := encodeDetachedSignature(sig)
+	if err != nil {
+		return "", err
+	}
+	sigPath := path + detachedSuffix
+	return sigPath, os.WriteFile(sigPath, []byte(text+"\n"), 0644)
+}
+
+/*
+Verifies the file at path against the detached signature in sigPath, or in
+path + ".sig" if sigPath is empty. The verifying key is looked up in kt by
+the signer fingerprint, or is fallback for signatures without one. Returns
+the signature and the key, which are only trustworthy if the error is nil.
+*/
+func verifyFile(kt *KeyTable, fallback *KeyObj, path, sigPath string) (*DetachedSignature, *KeyObj, error) {
+	if sigPath == "" {
+		sigPath = path + detachedSuffix
+	}
+	text, err := os.ReadFile(sigPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	sig, err := decodeDetachedSignature(string(text))
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := kt.signerKey(sig.Signer, fallback)
+	if err != nil {
+		return sig, nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	return sig, key, key.Verify(f, sig)
+}
+
+// Signing time of a detached signature in the format used for key creation dates.
+func signatureTime(sig *DetachedSignature) string {
+	return time.Unix(sig.Created, 0).Format(time.RFC1123)
+}
+
+// Path of the file a detached signature file belongs to.
+func signedFilePath(sigPath string) string { return strings.TrimSuffix(sigPath, detachedSuffix) }
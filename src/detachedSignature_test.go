@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A private key generated from pw on curve, as stored in the key table.
+func testKeyObj(t testing.TB, curve Curve, pw, id string) *KeyObj {
+	t.Helper()
+	key := &KeyObj{Id: id, KeyType: "PRIVATE"}
+	generateKeyPairOn(curve, key, pw, "owner")
+	return key
+}
+
+// Deterministic content that is never held in memory as a whole.
+func testStream(n int64) io.Reader {
+	return io.LimitReader(newCSHAKE256("", "stream"), n)
+}
+
+func TestDetachedSignature(t *testing.T) {
+	for _, curve := range supportedCurves {
+		key := testKeyObj(t, curve, "pw", "key-"+curve.Name())
+		if _, err := NewSigner(key, []byte("wrong")); !errors.Is(err, ErrAuthFailed) {
+			t.Fatalf("%s: wrong passphrase gave %v, want ErrAuthFailed", curve.Name(), err)
+		}
+		signer, err := NewSigner(key, []byte("pw"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		const size = 1<<20 + 17
+		sig, err := signer.Sign(testStream(size))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sig.Algorithm != curve.Name()+"-Schnorr-cSHAKE256" || sig.KeyID != key.Id {
+			t.Errorf("%s: signature metadata %q %q", curve.Name(), sig.Algorithm, sig.KeyID)
+		}
+		text, err := encodeDetachedSignature(sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(text) > 1024 {
+			t.Errorf("%s: %d byte signature for %d bytes of content", curve.Name(), len(text), size)
+		}
+		decoded, err := decodeDetachedSignature(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := key.Verify(testStream(size), decoded); err != nil {
+			t.Fatalf("%s: valid signature rejected: %v", curve.Name(), err)
+		}
+
+		edits := map[string]func(s *DetachedSignature){
+			"key ID":   func(s *DetachedSignature) { s.KeyID = "someone else" },
+			"created":  func(s *DetachedSignature) { s.Created++ },
+			"z":        func(s *DetachedSignature) { s.Z = new(big.Int).Add(s.Z, big.NewInt(1)) },
+			"z + q":    func(s *DetachedSignature) { s.Z = new(big.Int).Add(s.Z, curve.Order()) },
+			"negative": func(s *DetachedSignature) { s.H = new(big.Int).Neg(s.H) },
+		}
+		for name, edit := range edits {
+			bad := *decoded
+			edit(&bad)
+			if err := key.Verify(testStream(size), &bad); err == nil {
+				t.Errorf("%s: signature with modified %s accepted", curve.Name(), name)
+			}
+		}
+		if err := key.Verify(testStream(size-1), decoded); !errors.Is(err, ErrAuthFailed) {
+			t.Errorf("%s: truncated content gave %v, want ErrAuthFailed", curve.Name(), err)
+		}
+		other := testKeyObj(t, curve, "other", "other")
+		if err := other.Verify(testStream(size), decoded); !errors.Is(err, ErrAuthFailed) {
+			t.Errorf("%s: other key gave %v, want ErrAuthFailed", curve.Name(), err)
+		}
+	}
+	e521 := testKeyObj(t, E521Curve, "pw", "e521")
+	signer, _ := NewSigner(testKeyObj(t, Ed448Curve, "pw", "ed448"), []byte("pw"))
+	sig, _ := signer.Sign(strings.NewReader("content"))
+	if err := e521.Verify(strings.NewReader("content"), sig); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("Ed448 signature under an E521 key gave %v, want ErrAuthFailed", err)
+	}
+	sig.Algorithm = "RSA"
+	if err := e521.Verify(strings.NewReader("content"), sig); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("unknown algorithm gave %v, want ErrUnsupportedVersion", err)
+	}
+}
+
+func TestDetachedEd521Signature(t *testing.T) {
+	key := testKeyObj(t, E521Curve, "pw", "e521")
+	signer, _ := NewSigner(key, []byte("pw"))
+	if err := signer.SetAlgorithm(ed521Algorithm); err != nil {
+		t.Fatal(err)
+	}
+	sig, err := signer.Sign(strings.NewReader("content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig.Algorithm != "Ed521" || sig.H != nil || sig.Z != nil || len(sig.RS) != Ed521SignatureSize {
+		t.Fatalf("Ed521 signature %q with h %v, z %v and %d bytes", sig.Algorithm, sig.H, sig.Z, len(sig.RS))
+	}
+	text, _ := encodeDetachedSignature(sig)
+	decoded, err := decodeDetachedSignature(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Verify(strings.NewReader("content"), decoded); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	// a plain Ed521 verifier accepts it given the key's V and the signed data
+	V, _ := key.publicKey()
+	digest, _ := contentDigest(strings.NewReader("content"))
+	if err := Ed521Verify(ed521EncodePoint(asE521(V)), sig.signedData(digest), sig.RS,
+		&Ed521Options{Context: "SOAP-DETACHED"}); err != nil {
+		t.Errorf("Ed521Verify rejected the signature: %v", err)
+	}
+	if err := key.Verify(strings.NewReader("tampered"), decoded); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("tampered content gave %v, want ErrAuthFailed", err)
+	}
+	if err := testKeyObj(t, E521Curve, "other", "other").Verify(strings.NewReader("content"), decoded); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("other key gave %v, want ErrAuthFailed", err)
+	}
+
+	schnorr, _ := NewSigner(key, []byte("pw"))
+	schnorrSig, _ := schnorr.Sign(strings.NewReader("content"))
+	for name, edit := range map[string]func(s *DetachedSignature){
+		"truncated":    func(s *DetachedSignature) { s.RS = s.RS[1:] },
+		"with h and z": func(s *DetachedSignature) { s.H, s.Z = schnorrSig.H, schnorrSig.Z },
+		"as Schnorr":   func(s *DetachedSignature) { s.Algorithm = schnorrSig.Algorithm },
+		"Schnorr + RS": func(s *DetachedSignature) { *s = *schnorrSig; s.RS = sig.RS },
+		"missing R, S": func(s *DetachedSignature) { s.RS = nil },
+	} {
+		bad := *sig
+		edit(&bad)
+		text, _ := encodeDetachedSignature(&bad)
+		if _, err := decodeDetachedSignature(text); !errors.Is(err, ErrMalformed) {
+			t.Errorf("%s: got %v, want ErrMalformed", name, err)
+		}
+	}
+
+	ed448, _ := NewSigner(testKeyObj(t, Ed448Curve, "pw", "ed448"), []byte("pw"))
+	if err := ed448.SetAlgorithm(ed521Algorithm); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Ed521 with an Ed448 key gave %v, want ErrUnsupportedVersion", err)
+	}
+	if err := signer.SetAlgorithm("RSA"); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("unknown algorithm gave %v, want ErrUnsupportedVersion", err)
+	}
+}
+
+func TestSignAndVerifyFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "release.tar.gz")
+	if err := os.WriteFile(path, bytes.Repeat([]byte("payload "), 5000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	key := testKeyObj(t, E521Curve, "pw", "signer")
+	signer, _ := NewSigner(key, []byte("pw"))
+	sigPath, err := signFile(signer, path)
+	if err != nil || sigPath != path+".sig" {
+		t.Fatalf("signFile: %q, %v", sigPath, err)
+	}
+	other := testKeyObj(t, E521Curve, "other", "other")
+	kt := &KeyTable{keyList: map[string]KeyObj{"other": *other, "signer": *key}}
+	sig, found, err := verifyFile(kt, other, signedFilePath(sigPath), "")
+	if err != nil || found.Id != "signer" || !bytes.Equal(sig.Signer, key.fingerprint()) {
+		t.Fatalf("verifyFile: %v", err)
+	}
+	stranger := &KeyTable{keyList: map[string]KeyObj{"other": *other}}
+	if _, _, err := verifyFile(stranger, other, path, sigPath); !errors.Is(err, errUnknownSigner) {
+		t.Errorf("signature by a key not in the table gave %v, want errUnknownSigner", err)
+	}
+	os.WriteFile(path, []byte("tampered"), 0644)
+	if _, _, err := verifyFile(kt, nil, path, sigPath); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("tampered file gave %v, want ErrAuthFailed", err)
+	}
+	os.WriteFile(sigPath, []byte(formatSOAP([]byte("garbage"), detachedBegin, detachedEnd)), 0644)
+	if _, _, err := verifyFile(kt, nil, path, sigPath); !errors.Is(err, ErrMalformed) {
+		t.Errorf("garbled signature gave %v, want ErrMalformed", err)
+	}
+}
+
+// Detached and message signatures hash under different customization strings.
+func TestDetachedSignatureDomain(t *testing.T) {
+	key := testKeyObj(t, E521Curve, "pw", "signer")
+	V, _ := key.publicKey()
+	signer, _ := NewSigner(key, []byte("pw"))
+	detached, err := signer.Sign(strings.NewReader("content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, _ := contentDigest(strings.NewReader("content"))
+	data := detached.signedData(digest)
+	for _, version := range []byte{sigVersionLegacy, sigVersionDetached} {
+		if verify(V, &Signature{H: detached.H, Z: detached.Z, C: CurveE521, V: version}, &data) {
+			t.Errorf("detached signature verified as a version %d message signature", version)
+		}
+	}
+
+	// a message signature over data shaped like signedData
+	forged := *detached
+	legacy := schnorrSign(E521Curve, privateScalar(E521Curve, []byte("pw")), data, sigVersionLegacy)
+	forged.H, forged.Z = legacy.H, legacy.Z
+	if err := key.Verify(strings.NewReader("content"), &forged); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("message signature accepted as a detached signature: %v", err)
+	}
+}
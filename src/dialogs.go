@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 
@@ -69,10 +70,10 @@ func passwordEntryDialog(parent *gtk.Window, message string) (string, bool) {
 	return "", false
 }
 
-// Prompts user to enter key ownership data.
-// Returns true if operation completed, and false if
+// Prompts user to enter key ownership data. Key IDs are drawn
+// from rng. Returns true if operation completed, and false if
 // cancelled.
-func constructKey(ctx *WindowCtx, key *KeyObj) bool {
+func constructKey(ctx *WindowCtx, key *KeyObj, rng io.Reader) bool {
 
 	// Create a dialog
 	dialog, _ := gtk.DialogNew()
@@ -117,9 +118,12 @@ func constructKey(ctx *WindowCtx, key *KeyObj) bool {
 	confirm.SetVisibility(false)
 
 	//generate a random key id using sponge
-	r := generateRandomBytes(200)
-	r = append(r, 0x18) //Delim Suffix for key ID
-	key.Id = hex.EncodeToString(SpongeSqueeze(SpongeAbsorb(&r, 256), 48, 136))
+	id, err := newKeyID(rng, []byte{0x18}) //Delim Suffix for key ID
+	if err != nil {
+		dialog.Destroy()
+		return false
+	}
+	key.Id = id
 	key.Owner = "NONE"
 	key.KeyType = "PRIVATE"
 
@@ -132,7 +136,7 @@ func constructKey(ctx *WindowCtx, key *KeyObj) bool {
 		if matched {
 			ot, _ := owner.GetText()
 			password2, _ := confirm.GetText()
-			generateKeyPair(key, password2, ot)
+			generateKeyPairOn(ctx.keyCurve(), key, password2, ot)
 			dialog.Destroy()
 			return true
 		}
@@ -187,8 +191,7 @@ func showKeyDetails(ctx *WindowCtx) {
 	ID, _ := gtk.LabelNew("ID: " + key.Id)
 	Owner, _ := gtk.LabelNew("OWNER: " + key.Owner)
 	KEYTYPE, _ := gtk.LabelNew("KEY TYPE: " + key.KeyType)
-	PubKeyX, _ := gtk.LabelNew("PUB KEY X: ")
-	PubKeyY, _ := gtk.LabelNew("PUB KEY Y: ")
+	PubKey, _ := gtk.LabelNew("PUB KEY: ")
 	PrivKey, _ := gtk.LabelNew("PRIV KEY: ")
 	DateCreated, _ := gtk.LabelNew("DATE CREATED: " + key.DateCreated)
 	KeySig, _ := gtk.LabelNew("KEY SIG: ")
@@ -199,13 +202,14 @@ func showKeyDetails(ctx *WindowCtx) {
 	fixed.Put(KEYTYPE, 25, 95)
 
 	//create text boxes for larger key data
-	pubKeyXWindow := getScrollableTextArea(ctx, ctx.loadedKey.PubKeyX)
-	fixed.Put(PubKeyX, 25, 130)
-	fixed.Put(&pubKeyXWindow, 25, 155)
-
-	pubKeyYWindow := getScrollableTextArea(ctx, ctx.loadedKey.PubKeyY)
-	fixed.Put(PubKeyY, 250, 130)
-	fixed.Put(&pubKeyYWindow, 250, 155)
+	pubKeyHex := ""
+	if V, err := key.publicPoint(); err == nil {
+		enc, _ := V.MarshalBinary()
+		pubKeyHex = hex.EncodeToString(enc)
+	}
+	pubKeyWindow := getScrollableTextArea(ctx, pubKeyHex)
+	fixed.Put(PubKey, 25, 130)
+	fixed.Put(&pubKeyWindow, 25, 155)
 
 	privKeyWindow := getScrollableTextArea(ctx, ctx.loadedKey.PrivKey)
 	fixed.Put(PrivKey, 25, 270)
@@ -273,6 +277,41 @@ func exportKeyDialog(ctx *WindowCtx, name string) {
 	dialog.Destroy()
 }
 
+// A dialog that chooses where to save a binary result. Returns the
+// chosen filename, or false if the user cancelled.
+func saveResultDialog(ctx *WindowCtx) (string, bool) {
+	dialog, err := gtk.FileChooserDialogNewWith2Buttons("Save Decrypted Data", ctx.win,
+		gtk.FILE_CHOOSER_ACTION_SAVE,
+		"Cancel", gtk.RESPONSE_CANCEL,
+		"Save", gtk.RESPONSE_ACCEPT)
+	if err != nil {
+		return "", false
+	}
+	defer dialog.Destroy()
+	dialog.SetCurrentName("decrypted.bin")
+	if dialog.Run() == gtk.RESPONSE_ACCEPT {
+		return dialog.GetFilename(), true
+	}
+	return "", false
+}
+
+// A dialog that chooses an existing file. Returns the chosen filename,
+// or false if the user cancelled.
+func openFileDialog(ctx *WindowCtx, title string) (string, bool) {
+	dialog, err := gtk.FileChooserDialogNewWith2Buttons(title, ctx.win,
+		gtk.FILE_CHOOSER_ACTION_OPEN,
+		"_Cancel", gtk.RESPONSE_CANCEL,
+		"_Open", gtk.RESPONSE_ACCEPT)
+	if err != nil {
+		return "", false
+	}
+	defer dialog.Destroy()
+	if dialog.Run() == gtk.RESPONSE_ACCEPT {
+		return dialog.GetFilename(), true
+	}
+	return "", false
+}
+
 // A dialog that opens a key file. Handles any error in parsing file to key
 func importKeyDialog(ctx *WindowCtx) {
 
@@ -28,13 +28,14 @@ func testSig() {
 	k = new(big.Int).Mul(k, big.NewInt(4))
 	//create public signing key for message
 	U := E521GenPoint(0).SecMul(k)
-	uXBytes := U.x.Bytes()
+	uXBytes := U.AffineX().Bytes()
 	//get the tag for the message key
 	h := KMACXOF256(&uXBytes, &message, 512, "T")
 	//create public nonce for signature
 	h_bigInt := new(big.Int).SetBytes(h)
 	z := new(big.Int).Sub(k, new(big.Int).Mul(h_bigInt, s))
-	z = new(big.Int).Mod(z, &E521IdPoint().r)
+	r := new(E521).getR()
+	z = new(big.Int).Mod(z, &r)
 	// z = (k - hs) mod r
 	sig := Signature{H: h_bigInt, Z: z}
 	result, err := encodeSignature(&sig)
@@ -46,7 +47,7 @@ func testSig() {
 			fmt.Println("err")
 		} else {
 			U2 := E521GenPoint(0).SecMul(decoded.Z).Add(V.SecMul(decoded.H))
-			UXbytes := U2.x.Bytes()
+			UXbytes := U2.AffineX().Bytes()
 			h_p := KMACXOF256(&UXbytes, &message, 512, "T")
 			h2 := new(big.Int).SetBytes(h_p)
 			fmt.Println("H: ", h2)
@@ -68,11 +69,12 @@ func testEncDec() {
 	pw := big.NewInt(0)
 	pw = pw.SetBytes(pw_bytes)
 	pw = pw.Mul(pw, big.NewInt(4))
-	pw = pw.Mod(pw, &key.n)
+	n := key.getR()
+	pw = pw.Mod(pw, n.Lsh(&n, 2))
 
 	key = key.SecMul(pw)
 	message := []byte("test message")
-	cgEnc := encryptWithKey(key, &message)
+	cgEnc, _ := encryptWithKey(key, &message)
 	// Create a buffer to write the data to
 	var buf bytes.Buffer
 	// Create a new encoder and use it to encode the data
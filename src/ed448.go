@@ -0,0 +1,338 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+)
+
+/*
+Edwards448-Goldilocks, x^2 + y^2 = 1 + d(x^2)(y^2) over p = 2^448 - 2^224 - 1
+with d = -39081, as specified in RFC 7748 and RFC 8032. The generator has
+prime order q and the curve order is 4q.
+
+Affine points, their encoding and validation use math/big on public
+values. Point arithmetic runs in extended coordinates over the constant-time
+field of field448.go, and scalar multiplication by secrets uses the blinded
+fixed-length ladder of E521.SecMul.
+
+	https://www.rfc-editor.org/rfc/rfc8032#section-5.2
+*/
+type edwardsCurve struct {
+	id     byte
+	name   string
+	p, d   big.Int // field prime and curve constant d mod p
+	order  big.Int // prime order q of the generator
+	gx, gy big.Int // generator
+	size   int     // length of a compressed point: little-endian y, x sign in the top bit
+	sc     *scalarField
+}
+
+// The Edwards448-Goldilocks curve.
+var Ed448Curve Curve = newEd448()
+
+func newEd448() *edwardsCurve {
+	c := &edwardsCurve{id: CurveEd448, name: "Ed448", size: 57}
+	c.p.Set(&ed448P)
+	c.d.Mod(big.NewInt(-39081), &c.p)
+	c.order.SetString("181709681073901722637330951972001133588410340171829515070372549795146003961539585716195755291692375963310293709091662304773755859649779", 10)
+	c.gx.SetString("224580040295924300187604334099896036246789641632564134246125461686950415467406032909029192869357953282578032075146446173674602635247710", 10)
+	c.gy.SetString("298819210078481492676017930443930673437544040154080242095928241372331506189835876003536878655418784733982303233503462500531545062832660", 10)
+	c.sc = newScalarField(&c.order, 57)
+	return c
+}
+
+func (c *edwardsCurve) ID() byte              { return c.id }
+func (c *edwardsCurve) Name() string          { return c.name }
+func (c *edwardsCurve) Order() *big.Int       { return new(big.Int).Set(&c.order) }
+func (c *edwardsCurve) scalars() *scalarField { return c.sc }
+
+func (c *edwardsCurve) Generator() Point { return c.newPoint(&c.gx, &c.gy) }
+
+func (c *edwardsCurve) ScalarBaseMult(k *big.Int) Point { return c.Generator().ScalarMult(k) }
+
+// An affine point on an edwardsCurve, coordinates in [0, p).
+type edPoint struct {
+	c    *edwardsCurve
+	x, y big.Int
+}
+
+func (c *edwardsCurve) newPoint(x, y *big.Int) *edPoint {
+	P := &edPoint{c: c}
+	P.x.Mod(x, &c.p)
+	P.y.Mod(y, &c.p)
+	return P
+}
+
+func (c *edwardsCurve) identity() *edPoint { return c.newPoint(big.NewInt(0), big.NewInt(1)) }
+
+// Converts a Point known to lie on c, panicking otherwise.
+func (c *edwardsCurve) asPoint(P Point) *edPoint {
+	e, ok := P.(*edPoint)
+	if !ok || e.c != c {
+		panic(c.name + ": point of another curve")
+	}
+	return e
+}
+
+/*
+Extended coordinates (X:Y:Z:T) with x = X/Z, y = Y/Z and x*y = T/Z, over
+the constant-time field of field448.go. The formulas are those of
+E521Extended.go, which apply unchanged as Ed448 also has a = 1 and a
+non-square d.
+*/
+type edExt struct {
+	X, Y, Z, T fe448
+}
+
+// The curve constant d = -39081 of Ed448 as a field element.
+var ed448D = *new(fe448).Neg(fe448FromUint64(39081))
+
+// Bits of a blinded Ed448 scalar: k mod 4q is below 2^448 and the blind adds scalarBlindBits.
+const ed448LadderBits = 448 + scalarBlindBits
+
+// The identity point (0 : 1 : 1 : 0).
+func edExtIdentity() *edExt {
+	return &edExt{Y: *fe448FromUint64(1), Z: *fe448FromUint64(1)}
+}
+
+// Lifts an affine point to extended coordinates with Z = 1.
+func (P *edPoint) toExt() *edExt {
+	e := &edExt{Z: *fe448FromUint64(1)}
+	e.X.SetBigInt(&P.x)
+	e.Y.SetBigInt(&P.y)
+	e.T.Mul(&e.X, &e.Y)
+	return e
+}
+
+func (c *edwardsCurve) mod(a *big.Int) *big.Int { return a.Mod(a, &c.p) }
+
+// Converts back to affine coordinates using a single inversion of Z.
+func (c *edwardsCurve) fromExt(e *edExt) *edPoint {
+	zInv := new(fe448).Invert(&e.Z)
+	x := new(fe448).Mul(&e.X, zInv)
+	y := new(fe448).Mul(&e.Y, zInv)
+	return c.newPoint(x.BigInt(), y.BigInt())
+}
+
+// Unified addition add-2008-hwcd with a = 1, complete since d is not a square.
+func (p *edExt) add(q *edExt) *edExt {
+	var A, B, C, D, E, F, G, H, t fe448
+	A.Mul(&p.X, &q.X)
+	B.Mul(&p.Y, &q.Y)
+	C.Mul(C.Mul(&ed448D, &p.T), &q.T)
+	D.Mul(&p.Z, &q.Z)
+	E.Mul(E.Add(&p.X, &p.Y), t.Add(&q.X, &q.Y))
+	E.Sub(E.Sub(&E, &A), &B)
+	F.Sub(&D, &C)
+	G.Add(&D, &C)
+	H.Sub(&B, &A)
+	return edFromEFGH(&E, &F, &G, &H)
+}
+
+// Dedicated doubling dbl-2008-hwcd with a = 1.
+func (p *edExt) double() *edExt {
+	var A, B, C, E, F, G, H fe448
+	A.Square(&p.X)
+	B.Square(&p.Y)
+	C.Square(&p.Z)
+	C.Add(&C, &C)
+	E.Square(E.Add(&p.X, &p.Y))
+	E.Sub(E.Sub(&E, &A), &B)
+	G.Add(&A, &B)
+	F.Sub(&G, &C)
+	H.Sub(&A, &B)
+	return edFromEFGH(&E, &F, &G, &H)
+}
+
+// Final step shared by add and double.
+func edFromEFGH(E, F, G, H *fe448) *edExt {
+	out := &edExt{}
+	out.X.Mul(E, F)
+	out.Y.Mul(G, H)
+	out.T.Mul(E, H)
+	out.Z.Mul(F, G)
+	return out
+}
+
+// Swaps p and q if cond == 1 and leaves them unchanged if cond == 0, without branching.
+func (p *edExt) cswap(q *edExt, cond int) {
+	fe448Swap(&p.X, &q.X, cond)
+	fe448Swap(&p.Y, &q.Y, cond)
+	fe448Swap(&p.Z, &q.Z, cond)
+	fe448Swap(&p.T, &q.T, cond)
+}
+
+// Rescales all coordinates by a nonzero lambda. The point it represents is unchanged.
+func (p *edExt) scale(lambda *fe448) *edExt {
+	p.X.Mul(&p.X, lambda)
+	p.Y.Mul(&p.Y, lambda)
+	p.Z.Mul(&p.Z, lambda)
+	p.T.Mul(&p.T, lambda)
+	return p
+}
+
+// Returns a uniformly random nonzero element of the Ed448 field.
+func randomFe448() *fe448 {
+	buf := make([]byte, fe448Bytes+16)
+	if _, err := rand.Read(buf); err != nil {
+		panic("Ed448: unable to read randomness for coordinate blinding: " + err.Error())
+	}
+	e := new(fe448).SetBigInt(new(big.Int).SetBytes(buf))
+	return e.Select(fe448FromUint64(1), e, e.IsZero())
+}
+
+func (P *edPoint) Curve() Curve { return P.c }
+
+func (P *edPoint) AddPoint(q Point) Point {
+	return P.c.fromExt(P.toExt().add(P.c.asPoint(q).toExt()))
+}
+
+/*
+Multiplies by a secret scalar k as E521.SecMul does. The scalar is blinded
+as (k mod 4q) + m*4q for a random 64 bit m, which leaves the result
+unchanged for any point on the curve, and the starting coordinates are
+rescaled by random field elements. The ladder always runs ed448LadderBits
+iterations and selects operands with conditional swaps rather than branches.
+*/
+func (P *edPoint) ScalarMult(k *big.Int) Point {
+	R0, R1 := edExtIdentity(), P.toExt()
+	R0.scale(randomFe448())
+	R1.scale(randomFe448())
+
+	s := blindScalar(k, new(big.Int).Lsh(&P.c.order, 2), ed448LadderBits)
+	prev := 0
+	for i := ed448LadderBits - 1; i >= 0; i-- {
+		bit := int(s[len(s)-1-i/8]>>(i%8)) & 1
+		R0.cswap(R1, bit^prev)
+		prev = bit
+		R1 = R0.add(R1)
+		R0 = R0.double()
+	}
+	R0.cswap(R1, prev)
+	zeroize(s)
+	return P.c.fromExt(R0)
+}
+
+// Variable time sum of scalar multiples by interleaved double-and-add, for public inputs only.
+func (c *edwardsCurve) MultiScalarMul(points []Point, scalars []*big.Int) Point {
+	if len(points) != len(scalars) {
+		panic(c.name + ": MultiScalarMul called with mismatched points and scalars")
+	}
+	n := new(big.Int).Lsh(&c.order, 2)
+	exts := make([]*edExt, len(points))
+	ks := make([]*big.Int, len(points))
+	length := 0
+	for i := range points {
+		exts[i] = c.asPoint(points[i]).toExt()
+		ks[i] = new(big.Int).Mod(scalars[i], n)
+		if ks[i].BitLen() > length {
+			length = ks[i].BitLen()
+		}
+	}
+	acc := edExtIdentity()
+	for j := length - 1; j >= 0; j-- {
+		acc = acc.double()
+		for i := range exts {
+			if ks[i].Bit(j) == 1 {
+				acc = acc.add(exts[i])
+			}
+		}
+	}
+	return c.fromExt(acc)
+}
+
+func (P *edPoint) Equal(q Point) bool {
+	other, ok := q.(*edPoint)
+	return ok && other.c == P.c && P.x.Cmp(&other.x) == 0 && P.y.Cmp(&other.y) == 0
+}
+
+func (P *edPoint) IsIdentity() bool { return P.x.Sign() == 0 && P.y.Cmp(big.NewInt(1)) == 0 }
+
+func (P *edPoint) AffineX() *big.Int { return new(big.Int).Set(&P.x) }
+
+func (P *edPoint) isOnCurve() bool {
+	c := P.c
+	x2 := new(big.Int).Mul(&P.x, &P.x)
+	y2 := new(big.Int).Mul(&P.y, &P.y)
+	lhs := c.mod(new(big.Int).Add(x2, y2))
+	rhs := new(big.Int).Mul(x2, y2)
+	rhs = c.mod(rhs.Add(c.mod(rhs.Mul(rhs, &c.d)), big.NewInt(1)))
+	return lhs.Cmp(rhs) == 0
+}
+
+// Rejects points off the curve, the identity and points outside the prime order subgroup.
+func (P *edPoint) validate() error {
+	switch {
+	case !P.isOnCurve():
+		return wrapErr(ErrInvalidPoint, errors.New("point is not on "+P.c.name))
+	case P.IsIdentity():
+		return wrapErr(ErrInvalidPoint, errors.New("point is the identity"))
+	case !P.c.MultiScalarMul([]Point{P}, []*big.Int{&P.c.order}).IsIdentity():
+		return wrapErr(ErrInvalidPoint, errors.New("point is not in the prime order subgroup"))
+	}
+	return nil
+}
+
+// RFC 8032 encoding: y little-endian with the lsb of x in the top bit of the last byte.
+func (P *edPoint) MarshalBinary() ([]byte, error) {
+	out := make([]byte, P.c.size)
+	P.y.FillBytes(out)
+	reverse(out)
+	out[P.c.size-1] |= byte(P.x.Bit(0)) << 7
+	return out, nil
+}
+
+func (P *edPoint) MarshalUncompressed() []byte {
+	n := (P.c.p.BitLen() + 7) / 8
+	out := make([]byte, 2*n)
+	P.x.FillBytes(out[:n])
+	P.y.FillBytes(out[n:])
+	return out
+}
+
+/*
+Decodes the RFC 8032 encoding. Non-canonical inputs are rejected: y must be
+less than p, the unused bits of the last byte must be zero, and x = 0 may
+not carry a set sign bit. Subgroup membership is left to validate.
+*/
+func (c *edwardsCurve) DecodePoint(data []byte) (Point, error) {
+	if len(data) != c.size {
+		return nil, wrapErr(ErrMalformed, errors.New("invalid point encoding length"))
+	}
+	enc := append([]byte{}, data...)
+	sign := uint(enc[c.size-1] >> 7)
+	if enc[c.size-1]&0x7F != 0 {
+		return nil, wrapErr(ErrInvalidPoint, errors.New("non-canonical compressed point"))
+	}
+	enc[c.size-1] = 0
+	reverse(enc)
+	y := new(big.Int).SetBytes(enc)
+	if y.Cmp(&c.p) >= 0 {
+		return nil, wrapErr(ErrInvalidPoint, errors.New("y coordinate out of range"))
+	}
+	// x^2 = (y^2 - 1) / (d y^2 - 1)
+	y2 := c.mod(new(big.Int).Mul(y, y))
+	num := c.mod(new(big.Int).Sub(y2, big.NewInt(1)))
+	den := c.mod(new(big.Int).Sub(c.mod(new(big.Int).Mul(&c.d, y2)), big.NewInt(1)))
+	x2 := c.mod(num.Mul(num, new(big.Int).ModInverse(den, &c.p)))
+	// p = 3 mod 4, so a root is x2^((p+1)/4)
+	x := new(big.Int).Exp(x2, new(big.Int).Rsh(new(big.Int).Add(&c.p, big.NewInt(1)), 2), &c.p)
+	if c.mod(new(big.Int).Mul(x, x)).Cmp(x2) != 0 {
+		return nil, wrapErr(ErrInvalidPoint, errors.New("no point with given y"))
+	}
+	if x.Sign() == 0 && sign == 1 {
+		return nil, wrapErr(ErrInvalidPoint, errors.New("non-canonical compressed point"))
+	}
+	if x.Bit(0) != sign {
+		x.Sub(&c.p, x)
+	}
+	return c.newPoint(x, y), nil
+}
+
+// Reverses b in place.
+func reverse(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+// Ed448 public key derived from a key passphrase as in generateKeyPairOn.
+func testEd448Key(t testing.TB, pw string) (*KeyObj, Point) {
+	t.Helper()
+	key := &KeyObj{}
+	generateKeyPairOn(Ed448Curve, key, pw, "test")
+	V, err := key.publicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, V
+}
+
+func TestEd448Generator(t *testing.T) {
+	G := Ed448Curve.Generator()
+	if err := G.validate(); err != nil {
+		t.Fatalf("generator rejected: %v", err)
+	}
+	if !G.ScalarMult(Ed448Curve.Order()).IsIdentity() {
+		t.Error("qG != O")
+	}
+	sum := G.ScalarMult(big.NewInt(0))
+	for k := int64(1); k < 6; k++ {
+		sum = sum.AddPoint(G)
+		if !G.ScalarMult(big.NewInt(k)).Equal(sum) {
+			t.Fatalf("%dG differs from repeated addition", k)
+		}
+	}
+	// RFC 8032 section 5.2.5: the encoding of the base point
+	enc, _ := G.MarshalBinary()
+	want := "14fa30f25b790898adc8d74e2c13bdfdc4397ce61cffd33ad7c2a0051e9c78874098a36c7373ea4b62c7c9563720768824bcb66e71463f6900"
+	if got := hex.EncodeToString(enc); got != want {
+		t.Errorf("generator encodes as %s, want %s", got, want)
+	}
+}
+
+func TestEd448EncodingRoundTrip(t *testing.T) {
+	G := Ed448Curve.Generator()
+	for _, k := range []int64{1, 2, 3, 12345, -1} {
+		P := G.ScalarMult(big.NewInt(k))
+		enc, _ := P.MarshalBinary()
+		Q, err := Ed448Curve.DecodePoint(enc)
+		if err != nil {
+			t.Fatalf("%dG: %v", k, err)
+		}
+		if !Q.Equal(P) {
+			t.Errorf("%dG: decoded point differs", k)
+		}
+	}
+
+	enc, _ := G.MarshalBinary()
+	unusedBits := append([]byte{}, enc...)
+	unusedBits[56] |= 0x01
+	yTooLarge := make([]byte, 57)
+	for i := range yTooLarge[:56] {
+		yTooLarge[i] = 0xff
+	}
+	// y = 1 gives x = 0, which has no encoding with the sign bit set
+	negZero := make([]byte, 57)
+	negZero[0], negZero[56] = 1, 0x80
+	for name, bad := range map[string][]byte{"unused bits set": unusedBits, "y not reduced": yTooLarge, "negative zero": negZero} {
+		if _, err := Ed448Curve.DecodePoint(bad); !errors.Is(err, ErrInvalidPoint) {
+			t.Errorf("%s: got %v, want ErrInvalidPoint", name, err)
+		}
+	}
+	if _, err := Ed448Curve.DecodePoint(enc[:56]); !errors.Is(err, ErrMalformed) {
+		t.Errorf("short input: got %v, want ErrMalformed", err)
+	}
+}
+
+func TestEd448EncryptAndSign(t *testing.T) {
+	key, V := testEd448Key(t, "recipient")
+	if key.Curve != "Ed448" || V.Curve() != Ed448Curve {
+		t.Fatalf("key generated on %q", key.Curve)
+	}
+	msg := []byte("message on the Goldilocks curve")
+	raw, err := encryptWithKey(V, &msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cg, err := decodeECCryptogram(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cg.Curve != CurveEd448 {
+		t.Errorf("cryptogram tagged with curve %d", cg.Curve)
+	}
+	if m, err := decryptWithKey([]byte("recipient"), cg); err != nil || !bytes.Equal(m, msg) {
+		t.Fatalf("Ed448 cryptogram did not decrypt: %v", err)
+	}
+
+	rawSig, err := signWithKeyOn(Ed448Curve, []byte("recipient"), &msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := decodeSignature(rawSig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verify(V, sig, &msg) {
+		t.Fatal("Ed448 signature does not verify")
+	}
+	if verify(testPublicKey(t, "recipient"), sig, &msg) {
+		t.Error("Ed448 signature verified under an E521 key")
+	}
+}
+
+func TestVerifyBatchMixedCurves(t *testing.T) {
+	items := testSignedItems(t, 4)
+	_, V := testEd448Key(t, "carol")
+	for i := 0; i < 3; i++ {
+		msg := []byte{byte(i)}
+		raw, err := signWithKeyOn(Ed448Curve, []byte("carol"), &msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, _ := decodeSignature(raw)
+		items = append(items, SignedItem{PubKey: V, Message: msg, Sig: sig})
+	}
+	if bad := VerifyBatch(items); len(bad) != 0 {
+		t.Fatalf("valid mixed batch reported bad items %v", bad)
+	}
+	items[1].Message = []byte("tampered")
+	items[5].PubKey = items[0].PubKey // an Ed448 signature under an E521 key
+	if bad, want := VerifyBatch(items), []int{1, 5}; !reflect.DeepEqual(bad, want) {
+		t.Errorf("bad items %v, want %v", bad, want)
+	}
+}
+
+func TestEd448ScalarMult(t *testing.T) {
+	G := Ed448Curve.Generator()
+	q := Ed448Curve.Order()
+	P := G.ScalarMult(big.NewInt(987654321))
+	for _, k := range []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(2), new(big.Int).Sub(q, big.NewInt(1)), q,
+		new(big.Int).Lsh(q, 2), new(big.Int).Neg(big.NewInt(5)), new(big.Int).Lsh(big.NewInt(1), 447)} {
+		for _, B := range []Point{G, P} {
+			want := Ed448Curve.MultiScalarMul([]Point{B}, []*big.Int{k})
+			if got := B.ScalarMult(k); !got.Equal(want) || !B.ScalarMult(k).Equal(got) {
+				t.Errorf("ScalarMult(%v) differs from the variable time result", k)
+			}
+		}
+	}
+	// the 4-torsion point (0, -1) is unaffected by the multiple of 4q added when blinding
+	T := &edPoint{c: Ed448Curve.(*edwardsCurve)}
+	T.y.Sub(&T.c.p, big.NewInt(1))
+	if !T.ScalarMult(big.NewInt(3)).Equal(T) || !T.ScalarMult(big.NewInt(2)).IsIdentity() {
+		t.Error("blinding changed a multiple of a small order point")
+	}
+}
+
+// RFC 8032 section 7.4, test "Blank": the public key of a secret seed.
+func TestEd448RFC8032PublicKey(t *testing.T) {
+	seed, _ := hex.DecodeString("6c82a562cb808d10d632be89c8513ebf6c929f34ddfa8c9f63c9960ef6e348a3528c8a3fcc2f044e39a3fc5b94492f8f032e7549a20098f95b")
+	want := "5fd7449b59b461fd2ce787ec616ad46a1da1342485a70e1f8a0ea75d80e96778edf124769b46c7061bd6783df1e50f6cd1fa1abeafe8256180"
+	h := shake256(114, seed)[:57]
+	h[0] &^= 0x03
+	h[56] = 0
+	h[55] |= 0x80
+	s := new(big.Int).SetBytes(reversed(h))
+	enc, _ := Ed448Curve.ScalarBaseMult(s).MarshalBinary()
+	if got := hex.EncodeToString(enc); got != want {
+		t.Errorf("public key %s, want %s", got, want)
+	}
+}
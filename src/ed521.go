@@ -0,0 +1,300 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"math/big"
+)
+
+/*
+Ed521: deterministic EdDSA on E521 following RFC 8032, with every choice
+left open by the generic scheme made the way Ed448 makes it.
+
+	B = E521GenPoint(0), L = r, c = 2, n = 520, b = 528
+	H(x) = SHAKE256(x, 132)
+	dom(f, C) = “SigEd521” || octet(f) || octet(len(C)) || C
+
+Points are encoded as in RFC 8032 section 5.2.2: y in 66 little-endian
+bytes with the least significant bit of x in the top bit. Scalars are 66
+little-endian bytes. Ed521ph signs PH(M) = SHAKE256(M, 64) instead of M.
+
+Keys are a 66 byte random seed, hashed to the secret scalar and the nonce
+prefix. Signatures are R || S, 132 bytes. Detached signatures may also use
+the passphrase-derived E521 keys of model.go, whose public key V = s*G is
+already a valid A: the scalar is used as is and the prefix is derived from
+it, see ed521Prefix.
+
+	https://www.rfc-editor.org/rfc/rfc8032
+*/
+
+const (
+	Ed521SeedSize       = 66                                 // length of a private key seed
+	Ed521PublicKeySize  = 66                                 // length of an encoded public key
+	Ed521PrivateKeySize = Ed521SeedSize + Ed521PublicKeySize // seed || public key
+	Ed521SignatureSize  = 2 * ed521EncodingSize              // R || S
+	ed521EncodingSize   = 66                                 // b/8 bytes for points and scalars
+	ed521HashSize       = 2 * ed521EncodingSize              // 2b bits of SHAKE256 output
+	ed521PreHashSize    = 64                                 // output length of PH
+	ed521MaxContext     = 255
+)
+
+// Domain separation prefix of every Ed521 hash, as “SigEd448” for Ed448.
+const ed521DomPrefix = "SigEd521"
+
+// An Ed521 public key, the encoding of A.
+type Ed521PublicKey []byte
+
+// An Ed521 private key, the seed followed by the public key.
+type Ed521PrivateKey []byte
+
+// Options of an Ed521 signature. The zero value selects plain Ed521 without a context.
+type Ed521Options struct {
+	Context string // up to 255 bytes binding the signature to an application
+	PreHash bool   // Ed521ph: sign SHAKE256(M, 64) rather than M
+}
+
+// Generates an Ed521 key pair from 66 bytes of rng.
+func GenerateEd521Key(rng io.Reader) (Ed521PublicKey, Ed521PrivateKey, error) {
+	seed, err := readRandomBytes(rng, Ed521SeedSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zeroize(seed)
+	priv, err := NewEd521KeyFromSeed(seed)
+	if err != nil {
+		return nil, nil, err
+	}
+	return priv.Public(), priv, nil
+}
+
+// Derives the private key of a seed, as RFC 8032 section 5.2.5.
+func NewEd521KeyFromSeed(seed []byte) (Ed521PrivateKey, error) {
+	if len(seed) != Ed521SeedSize {
+		return nil, wrapErr(ErrMalformed, errors.New("invalid Ed521 seed length"))
+	}
+	s, prefix := ed521ExpandSeed(seed)
+	zeroize(prefix)
+	priv := make(Ed521PrivateKey, 0, Ed521PrivateKeySize)
+	priv = append(priv, seed...)
+	return append(priv, ed521EncodePoint(E521GenMul(s.BigInt()))...), nil
+}
+
+// The public key of priv.
+func (priv Ed521PrivateKey) Public() Ed521PublicKey {
+	return append(Ed521PublicKey{}, priv[Ed521SeedSize:]...)
+}
+
+// The seed of priv.
+func (priv Ed521PrivateKey) Seed() []byte {
+	return append([]byte{}, priv[:Ed521SeedSize]...)
+}
+
+/*
+Hashes the seed and prunes the first half into the secret scalar s: the two
+lowest bits and the bits above 520 are cleared and bit 520 is set. The
+second half is the prefix the nonce is derived from.
+*/
+func ed521ExpandSeed(seed []byte) (*Scalar, []byte) {
+	h := shake256(ed521HashSize, seed)
+	defer zeroize(h)
+	h[0] &^= 0x03
+	h[ed521EncodingSize-1] = 0x01
+	s := scalarFromLittleEndian(h[:ed521EncodingSize])
+	return s, append([]byte{}, h[ed521EncodingSize:]...)
+}
+
+// SHAKE256 of the concatenated inputs, n bytes long.
+func shake256(n int, inputs ...[]byte) []byte {
+	k := newCSHAKE256("", "")
+	for _, in := range inputs {
+		k.Write(in)
+	}
+	out := make([]byte, n)
+	k.Read(out)
+	return out
+}
+
+// Reduces a little-endian integer mod r.
+func scalarFromLittleEndian(b []byte) *Scalar {
+	be := append([]byte{}, b...)
+	reverse(be)
+	defer zeroize(be)
+	return new(Scalar).SetUniformBytes(be)
+}
+
+// The 66 byte little-endian encoding of a scalar.
+func scalarToLittleEndian(s *Scalar) []byte {
+	b := s.Bytes()
+	reverse(b)
+	return b
+}
+
+// dom(f, C) for the options, or an error wrapping ErrMalformed if the context is too long.
+func (opts *Ed521Options) dom() ([]byte, error) {
+	if len(opts.Context) > ed521MaxContext {
+		return nil, wrapErr(ErrMalformed, errors.New("Ed521 context longer than 255 bytes"))
+	}
+	var ph byte
+	if opts.PreHash {
+		ph = 1
+	}
+	dom := append([]byte(ed521DomPrefix), ph, byte(len(opts.Context)))
+	return append(dom, opts.Context...), nil
+}
+
+// The message as signed: M, or PH(M) for Ed521ph.
+func (opts *Ed521Options) message(m []byte) []byte {
+	if opts.PreHash {
+		return shake256(ed521PreHashSize, m)
+	}
+	return m
+}
+
+/*
+Signs message with priv, as RFC 8032 section 5.2.6:
+
+	(s, prefix) <- H(seed) pruned
+	r <- H(dom || prefix || M') mod L;  R <- [r]B
+	k <- H(dom || R || A || M') mod L;  S <- (r + k s) mod L
+	return: R || S
+
+opts may be nil for plain Ed521. Returns an error wrapping ErrMalformed
+for a malformed key or an overlong context.
+*/
+func Ed521Sign(priv Ed521PrivateKey, message []byte, opts *Ed521Options) ([]byte, error) {
+	if opts == nil {
+		opts = &Ed521Options{}
+	}
+	if len(priv) != Ed521PrivateKeySize {
+		return nil, wrapErr(ErrMalformed, errors.New("invalid Ed521 private key length"))
+	}
+	dom, err := opts.dom()
+	if err != nil {
+		return nil, err
+	}
+	s, prefix := ed521ExpandSeed(priv[:Ed521SeedSize])
+	defer zeroize(prefix)
+	return ed521SignExpanded(s, prefix, priv[Ed521SeedSize:], opts.message(message), dom), nil
+}
+
+// The signing steps of Ed521Sign after the key is expanded, with M' and dom already computed.
+func ed521SignExpanded(s *Scalar, prefix, pub, m, dom []byte) []byte {
+	rh := shake256(ed521HashSize, dom, prefix, m)
+	r := scalarFromLittleEndian(rh)
+	zeroize(rh)
+	R := ed521EncodePoint(E521GenMul(r.BigInt()))
+	k := scalarFromLittleEndian(shake256(ed521HashSize, dom, R, pub, m))
+	S := r.Add(r, k.Mul(k, s))
+	return append(R, scalarToLittleEndian(S)...)
+}
+
+/*
+Derives the nonce prefix of a passphrase-derived scalar s, which has no
+seed to take the second half of H(seed) from:
+
+	prefix <- KMACXOF256(s, “”, 528, “ED521-PREFIX”)
+*/
+func ed521Prefix(s *Scalar) []byte {
+	sBytes := s.Bytes()
+	defer zeroize(sBytes)
+	return KMACXOF256(&sBytes, &[]byte{}, 8*ed521EncodingSize, "ED521-PREFIX")
+}
+
+/*
+Verifies an Ed521 signature, as RFC 8032 section 5.2.7 with the cofactored
+equation
+
+	[4][S]B = [4]R + [4][k]A
+
+S must be below L and A and R must be canonical encodings of curve points.
+Public keys of small order are rejected, as any signature would verify
+under them.
+
+	return: nil, an error wrapping ErrMalformed or ErrInvalidPoint for a
+	malformed key, signature length or context, or ErrAuthFailed if the
+	signature does not verify, including non-canonical R and S
+*/
+func Ed521Verify(pub Ed521PublicKey, message, sig []byte, opts *Ed521Options) error {
+	if opts == nil {
+		opts = &Ed521Options{}
+	}
+	if len(sig) != Ed521SignatureSize {
+		return wrapErr(ErrMalformed, errors.New("invalid Ed521 signature length"))
+	}
+	dom, err := opts.dom()
+	if err != nil {
+		return err
+	}
+	A, err := ed521DecodePoint(pub)
+	if err != nil {
+		return err
+	}
+	if A.toExtended().clearCofactor().toAffine().IsIdentity() {
+		return wrapErr(ErrInvalidPoint, errors.New("Ed521 public key has small order"))
+	}
+	encR := sig[:ed521EncodingSize]
+	R, err := ed521DecodePoint(encR)
+	if err != nil {
+		return wrapErr(ErrAuthFailed, err)
+	}
+	Sbytes := append([]byte{}, sig[ed521EncodingSize:]...)
+	reverse(Sbytes)
+	S, err := new(Scalar).SetCanonicalBytes(Sbytes)
+	if err != nil {
+		return wrapErr(ErrAuthFailed, err)
+	}
+	m := opts.message(message)
+	k := scalarFromLittleEndian(shake256(ed521HashSize, dom, encR, pub, m))
+
+	// S, k, A and R all come from the signature or the public key
+	check := MultiScalarMul([]*E521{E521GenPoint(0), A.getOpposite(), R.getOpposite()},
+		[]*big.Int{S.BigInt(), k.BigInt(), big.NewInt(1)})
+	if !check.toExtended().clearCofactor().toAffine().IsIdentity() {
+		return ErrAuthFailed
+	}
+	return nil
+}
+
+// RFC 8032 encoding of an E521 point: y little-endian with the lsb of x in bit 527.
+func ed521EncodePoint(P *E521) []byte {
+	out := P.y.Bytes()
+	reverse(out)
+	out[ed521EncodingSize-1] |= byte(P.x.lsb()) << 7
+	return out
+}
+
+/*
+Decodes an RFC 8032 encoding of an E521 point. Non-canonical inputs are
+rejected with an error wrapping ErrInvalidPoint: y must be less than p,
+bits 521 to 526 must be zero, and x = 0 may not carry a set sign bit.
+*/
+func ed521DecodePoint(data []byte) (*E521, error) {
+	if len(data) != ed521EncodingSize {
+		return nil, wrapErr(ErrMalformed, errors.New("invalid point encoding length"))
+	}
+	enc := append([]byte{}, data...)
+	sign := uint(enc[ed521EncodingSize-1] >> 7)
+	if enc[ed521EncodingSize-1]&0x7E != 0 {
+		return nil, wrapErr(ErrInvalidPoint, errors.New("non-canonical compressed point"))
+	}
+	enc[ed521EncodingSize-1] &= 0x01
+	reverse(enc)
+	var P E521
+	if _, ok := P.y.SetBytes(enc); ok != 1 {
+		return nil, wrapErr(ErrInvalidPoint, errors.New("y coordinate out of range"))
+	}
+	// x^2 = (1 - y^2) / (1 - d y^2)
+	y2 := new(fieldElement).Square(&P.y)
+	num := new(fieldElement).Sub(feFromUint64(1), y2)
+	den := new(fieldElement).Mul(&e521D, y2)
+	den.Sub(feFromUint64(1), den)
+	x, ok := sqrt(num.Mul(num, den.Invert(den)), sign)
+	if ok != 1 {
+		return nil, wrapErr(ErrInvalidPoint, errors.New("no point with given y"))
+	}
+	if x.IsZero() == 1 && sign == 1 {
+		return nil, wrapErr(ErrInvalidPoint, errors.New("non-canonical compressed point"))
+	}
+	P.x = *x
+	return &P, nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const ed521VectorFile = "ed521.json"
+
+// An Ed521 signature in the layout of the RFC 8032 test vectors, all fields hex.
+type ed521Vector struct {
+	Name      string `json:"name"`
+	Secret    string `json:"secret"`  // seed
+	Public    string `json:"public"`  // encoded A
+	Message   string `json:"message"` // M
+	Context   string `json:"context"` // C
+	PreHash   bool   `json:"prehash"` // Ed521ph
+	Signature string `json:"signature"`
+}
+
+func (v *ed521Vector) options() *Ed521Options {
+	ctx, _ := hex.DecodeString(v.Context)
+	return &Ed521Options{Context: string(ctx), PreHash: v.PreHash}
+}
+
+// Inputs for the checked in vectors, with seeds derived from the names.
+func ed521VectorInputs() []ed521Vector {
+	hexOf := func(s string) string { return hex.EncodeToString([]byte(s)) }
+	inputs := []ed521Vector{
+		{Name: "blank"},
+		{Name: "1 octet", Message: "03"},
+		{Name: "abc", Message: hexOf("abc")},
+		{Name: "abc with context", Message: hexOf("abc"), Context: hexOf("foo")},
+		{Name: "abc, other context", Message: hexOf("abc"), Context: hexOf("bar")},
+		{Name: "prehash abc", Message: hexOf("abc"), PreHash: true},
+		{Name: "prehash abc with context", Message: hexOf("abc"), Context: hexOf("foo"), PreHash: true},
+		{Name: "1023 octets", Message: hex.EncodeToString(shake256(1023, []byte("Ed521 long message")))},
+		{Name: "255 octet context", Message: hexOf("context at its limit"), Context: hex.EncodeToString(bytes.Repeat([]byte{0xc7}, 255))},
+	}
+	for i := range inputs {
+		inputs[i].Secret = hex.EncodeToString(shake256(Ed521SeedSize, []byte("ED521-TEST-VECTOR"), []byte(inputs[i].Name)))
+	}
+	return inputs
+}
+
+func TestEd521KnownAnswerVectors(t *testing.T) {
+	path := filepath.Join("testdata", ed521VectorFile)
+	if *updateVectors {
+		vectors := ed521VectorInputs()
+		for i := range vectors {
+			v := &vectors[i]
+			seed, _ := hex.DecodeString(v.Secret)
+			msg, _ := hex.DecodeString(v.Message)
+			priv, _ := NewEd521KeyFromSeed(seed)
+			sig, err := Ed521Sign(priv, msg, v.options())
+			if err != nil {
+				t.Fatal(err)
+			}
+			v.Public, v.Signature = hex.EncodeToString(priv.Public()), hex.EncodeToString(sig)
+		}
+		data, _ := json.MarshalIndent(vectors, "", "  ")
+		if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []ed521Vector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	for i := range vectors {
+		v := &vectors[i]
+		seed, _ := hex.DecodeString(v.Secret)
+		msg, _ := hex.DecodeString(v.Message)
+		want, _ := hex.DecodeString(v.Signature)
+		priv, err := NewEd521KeyFromSeed(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(priv.Public()); got != v.Public {
+			t.Errorf("%s: public key %s, want %s", v.Name, got, v.Public)
+		}
+		sig, err := Ed521Sign(priv, msg, v.options())
+		if err != nil || !bytes.Equal(sig, want) {
+			t.Errorf("%s: signature mismatch: %v", v.Name, err)
+		}
+		if err := Ed521Verify(priv.Public(), msg, want, v.options()); err != nil {
+			t.Errorf("%s: vector rejected: %v", v.Name, err)
+		}
+		if !referenceEd521Check(seed, msg, want, v.options()) {
+			t.Errorf("%s: vector fails the reference check", v.Name)
+		}
+	}
+}
+
+/*
+Recomputes a signature's public key and verification equation with the
+big.Int reference arithmetic of E521Reference_test.go, from RFC 8032
+directly: s from the pruned hash of the seed, A = [s]B, and
+[S]B = R + [k]A, which holds without the cofactor for honest signatures.
+*/
+func referenceEd521Check(seed, msg, sig []byte, opts *Ed521Options) bool {
+	le := func(b []byte) *big.Int { return new(big.Int).SetBytes(reversed(b)) }
+	h := shake256(132, seed)
+	s := le(h[:66])
+	s.SetBit(s, 0, 0).SetBit(s, 1, 0)
+	for i := 521; i < 528; i++ {
+		s.SetBit(s, i, 0)
+	}
+	s.SetBit(s, 520, 1)
+	B := refFrom(E521GenPoint(0))
+	A := B.mul(s)
+	encA := new(big.Int).Set(A.y)
+	encA.SetBit(encA, 527, A.x.Bit(0))
+	pub := reversed(encA.FillBytes(make([]byte, 66)))
+	R, err := ed521DecodePoint(sig[:66])
+	if err != nil {
+		return false
+	}
+	m := msg
+	ph := byte(0)
+	if opts.PreHash {
+		m, ph = shake256(64, msg), 1
+	}
+	dom := append([]byte("SigEd521"), ph, byte(len(opts.Context)))
+	dom = append(dom, opts.Context...)
+	k := new(big.Int).Mod(le(shake256(132, dom, sig[:66], pub, m)), &scalarOrder)
+	lhs := B.mul(le(sig[66:]))
+	rhs := refFrom(R).add(A.mul(k))
+	return lhs.x.Cmp(rhs.x) == 0 && lhs.y.Cmp(rhs.y) == 0
+}
+
+func TestEd521Rejections(t *testing.T) {
+	pub, priv, err := GenerateEd521Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("transfer 10")
+	opts := &Ed521Options{Context: "app"}
+	sig, err := Ed521Sign(priv, msg, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Ed521Verify(pub, msg, sig, opts); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	S := new(big.Int).SetBytes(reversed(sig[ed521EncodingSize:]))
+	withS := func(S *big.Int) []byte {
+		b := make([]byte, ed521EncodingSize)
+		S.FillBytes(b)
+		return append(append([]byte{}, sig[:ed521EncodingSize]...), reversed(b)...)
+	}
+	flipped := append([]byte{}, sig...)
+	flipped[10] ^= 1
+	otherPub, _, _ := GenerateEd521Key(rand.Reader)
+	cases := []struct {
+		name string
+		pub  Ed521PublicKey
+		msg  []byte
+		sig  []byte
+		opts *Ed521Options
+		want error
+	}{
+		{"other message", pub, []byte("transfer 100"), sig, opts, ErrAuthFailed},
+		{"other key", otherPub, msg, sig, opts, ErrAuthFailed},
+		{"no context", pub, msg, sig, nil, ErrAuthFailed},
+		{"other context", pub, msg, sig, &Ed521Options{Context: "app2"}, ErrAuthFailed},
+		{"prehash", pub, msg, sig, &Ed521Options{Context: "app", PreHash: true}, ErrAuthFailed},
+		{"modified R", pub, msg, flipped, opts, ErrAuthFailed},
+		{"S + L", pub, msg, withS(new(big.Int).Add(S, &scalarOrder)), opts, ErrAuthFailed},
+		{"S + 4L", pub, msg, withS(new(big.Int).Add(S, new(big.Int).Lsh(&scalarOrder, 2))), opts, ErrAuthFailed},
+		{"truncated", pub, msg, sig[1:], opts, ErrMalformed},
+		{"long context", pub, msg, sig, &Ed521Options{Context: strings.Repeat("c", 256)}, ErrMalformed},
+		{"identity key", ed521EncodePoint(E521IdPoint()), msg, sig, opts, ErrInvalidPoint},
+		{"low order key", ed521EncodePoint(NewE521XY(*big.NewInt(0), *new(big.Int).Sub(&e521P, big.NewInt(1)))), msg, sig, opts, ErrInvalidPoint},
+	}
+	for _, c := range cases {
+		if err := Ed521Verify(c.pub, c.msg, c.sig, c.opts); !errors.Is(err, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.want)
+		}
+	}
+	if _, err := Ed521Sign(priv, msg, &Ed521Options{Context: strings.Repeat("c", 256)}); !errors.Is(err, ErrMalformed) {
+		t.Errorf("signing with a 256 byte context gave %v", err)
+	}
+}
+
+func TestEd521PointEncoding(t *testing.T) {
+	G := E521GenPoint(0)
+	for _, P := range []*E521{G, G.getOpposite(), E521IdPoint(), G.SecMul(big.NewInt(12345))} {
+		enc := ed521EncodePoint(P)
+		Q, err := ed521DecodePoint(enc)
+		if err != nil || !Q.Equals(P) {
+			t.Fatalf("%x did not round trip: %v", enc, err)
+		}
+	}
+	p := e521P.Bytes()
+	nonCanonical := map[string][]byte{
+		"y = p":           reversed(append(make([]byte, ed521EncodingSize-len(p)), p...)),
+		"unused bits set": append(ed521EncodePoint(G)[:ed521EncodingSize-1], 0x02),
+		"x = 0, sign set": append(ed521EncodePoint(E521IdPoint())[:ed521EncodingSize-1], 0x80),
+	}
+	for name, enc := range nonCanonical {
+		if _, err := ed521DecodePoint(enc); !errors.Is(err, ErrInvalidPoint) {
+			t.Errorf("%s: got %v, want ErrInvalidPoint", name, err)
+		}
+	}
+}
+
+// A reversed copy of b.
+func reversed(b []byte) []byte {
+	out := append([]byte{}, b...)
+	reverse(out)
+	return out
+}
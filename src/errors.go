@@ -0,0 +1,27 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors returned by the model. Callers should test for these
+// with errors.Is, as decoders wrap them with additional detail.
+var (
+	ErrAuthFailed         = errors.New("authentication failed")        // tag or signature mismatch
+	ErrMalformed          = errors.New("malformed data")               // input could not be parsed
+	ErrUnsupportedVersion = errors.New("unsupported format version")   // unknown format flags or version
+	ErrInvalidPoint       = errors.New("invalid elliptic curve point") // point is not a valid group element
+)
+
+// Wraps a sentinel error with the underlying cause.
+func wrapErr(sentinel error, cause error) error {
+	return fmt.Errorf("%w: %v", sentinel, cause)
+}
+
+// Overwrites a buffer holding sensitive data.
+func zeroize(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
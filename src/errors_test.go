@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBadTagReturnsNoPlaintext(t *testing.T) {
+	msg := []byte("attack at dawn")
+	raw, err := encryptWithPW([]byte("pw"), &msg, withPadding(flagPadme))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sym := func(change func(cg *SymCryptogram)) *SymCryptogram {
+		cg, _ := decodeSymCryptogram(raw)
+		change(cg)
+		return cg
+	}
+	for name, cg := range map[string]*SymCryptogram{
+		"flipped tag":        sym(func(cg *SymCryptogram) { cg.T[0] ^= 1 }),
+		"flipped ciphertext": sym(func(cg *SymCryptogram) { cg.C[0] ^= 1 }),
+		"truncated tag":      sym(func(cg *SymCryptogram) { cg.T = cg.T[:32] }),
+		"changed flags":      sym(func(cg *SymCryptogram) { cg.F = flagPow2 }),
+	} {
+		if m, err := decryptWithPW([]byte("pw"), cg); err != ErrAuthFailed || m != nil {
+			t.Errorf("symmetric, %s: got %v, want ErrAuthFailed and no plaintext", name, err)
+		}
+	}
+	if m, err := decryptWithPW([]byte("other"), sym(func(*SymCryptogram) {})); err != ErrAuthFailed || m != nil {
+		t.Errorf("symmetric, wrong password: got %v, want ErrAuthFailed and no plaintext", err)
+	}
+
+	rawEC, err := encryptWithKey(testPublicKey(t, "recipient"), &msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec := func(change func(cg *ECCryptogram)) *ECCryptogram {
+		cg, _ := decodeECCryptogram(rawEC)
+		change(cg)
+		return cg
+	}
+	for name, cg := range map[string]*ECCryptogram{
+		"flipped tag":        ec(func(cg *ECCryptogram) { cg.T[63] ^= 1 }),
+		"flipped ciphertext": ec(func(cg *ECCryptogram) { cg.C[len(cg.C)-1] ^= 1 }),
+		"added flags":        ec(func(cg *ECCryptogram) { cg.F = flagDeflate }),
+	} {
+		if m, err := decryptWithKey([]byte("recipient"), cg); !errors.Is(err, ErrAuthFailed) || m != nil {
+			t.Errorf("EC, %s: got %v, want ErrAuthFailed and no plaintext", name, err)
+		}
+	}
+	if m, err := decryptWithKey([]byte("other"), ec(func(*ECCryptogram) {})); !errors.Is(err, ErrAuthFailed) || m != nil {
+		t.Errorf("EC, wrong key: got %v, want ErrAuthFailed and no plaintext", err)
+	}
+}
+
+func TestUnknownFlagsUnsupported(t *testing.T) {
+	msg := []byte("attack at dawn")
+	raw, _ := encryptWithPW([]byte("pw"), &msg)
+	cg, _ := decodeSymCryptogram(raw)
+	cg.F = 1 << 7
+	if m, err := decryptWithPW([]byte("pw"), cg); !errors.Is(err, ErrUnsupportedVersion) || m != nil {
+		t.Errorf("got %v, want ErrUnsupportedVersion", err)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"crypto/subtle"
+	"math/big"
+)
+
+/*
+Constant-time arithmetic in GF(p) for the Goldilocks prime p = 2^448 - 2^224 - 1,
+the field of Ed448, following the layout of field521.go.
+
+An element is held in eight unsigned 64-bit limbs in radix 2^56, so that
+value = l[0] + l[1]*2^56 + ... + l[7]*2^392. When fully reduced every limb
+holds 56 bits. Between operations limbs may carry a few extra bits of
+headroom. Reduction uses 2^448 = 2^224 + 1 (mod p); as 224 = 4*56 a carry
+out of the top limb folds into limbs 0 and 4.
+No operation branches on, or indexes memory by, the value of an element.
+*/
+type fe448 [8]uint64
+
+const (
+	fe448Limbs = 8
+	fe448Bytes = 56
+	fe448Mask  = 1<<56 - 1
+)
+
+// 4p, added before subtraction so that limbs never underflow
+var fe448FourP = fe448{
+	4 * fe448Mask, 4 * fe448Mask, 4 * fe448Mask, 4 * fe448Mask,
+	4 * (fe448Mask - 1), 4 * fe448Mask, 4 * fe448Mask, 4 * fe448Mask,
+}
+
+// 2^448 - p = 2^224 + 1
+var fe448PComplement = fe448{1, 0, 0, 0, 1, 0, 0, 0}
+
+// The field prime of Ed448.
+var ed448P = func() big.Int {
+	var p big.Int
+	p.Sub(new(big.Int).Lsh(big.NewInt(1), 448), new(big.Int).Lsh(big.NewInt(1), 224))
+	return *p.Sub(&p, big.NewInt(1))
+}()
+
+// Returns a new element set to the small integer v.
+func fe448FromUint64(v uint64) *fe448 {
+	e := &fe448{}
+	e[0] = v & fe448Mask
+	e[1] = v >> 56
+	return e
+}
+
+// Sets e = a and returns e.
+func (e *fe448) Set(a *fe448) *fe448 {
+	*e = *a
+	return e
+}
+
+/*
+Propagates carries so that each limb fits its width plus at most one bit.
+The carry out of the top limb wraps to limbs 0 and 4 since 2^448 = 2^224 + 1.
+*/
+func (e *fe448) carry() *fe448 {
+	for i := 0; i < fe448Limbs-1; i++ {
+		e[i+1] += e[i] >> 56
+		e[i] &= fe448Mask
+	}
+	c := e[7] >> 56
+	e[7] &= fe448Mask
+	e[0] += c
+	e[4] += c
+	return e
+}
+
+// Sets e = a + b and returns e.
+func (e *fe448) Add(a, b *fe448) *fe448 {
+	for i := range e {
+		e[i] = a[i] + b[i]
+	}
+	return e.carry()
+}
+
+// Sets e = a - b and returns e.
+func (e *fe448) Sub(a, b *fe448) *fe448 {
+	for i := range e {
+		e[i] = a[i] + fe448FourP[i] - b[i]
+	}
+	return e.carry()
+}
+
+// Sets e = -a and returns e.
+func (e *fe448) Neg(a *fe448) *fe448 {
+	return e.Sub(&fe448{}, a)
+}
+
+/*
+Sets e = a * b and returns e. Schoolbook multiplication into sixteen 128-bit
+columns. Column k >= 8 has weight 2^(56(k-8)) * 2^448 and is folded into
+columns k - 8 and k - 4; columns are folded from the top so that those
+landing above column 7 are folded again.
+*/
+func (e *fe448) Mul(a, b *fe448) *fe448 {
+	var t [2 * fe448Limbs]uint128
+	for i := 0; i < fe448Limbs; i++ {
+		for j := 0; j < fe448Limbs; j++ {
+			t[i+j].addMul(a[i], b[j])
+		}
+	}
+	for k := 2*fe448Limbs - 1; k >= fe448Limbs; k-- {
+		t[k-8].add(t[k])
+		t[k-4].add(t[k])
+	}
+	for i := 0; i < fe448Limbs-1; i++ {
+		t[i+1].add(t[i].shr(56))
+		e[i] = t[i].lo & fe448Mask
+	}
+	e[7] = t[7].lo & fe448Mask
+	c := t[7].shr(56)
+	lo, mid := c, c
+	lo.add(uint128{lo: e[0]})
+	e[0] = lo.lo & fe448Mask
+	e[1] += lo.shr(56).lo
+	mid.add(uint128{lo: e[4]})
+	e[4] = mid.lo & fe448Mask
+	e[5] += mid.shr(56).lo
+	return e.carry()
+}
+
+// Sets e = a^2 and returns e.
+func (e *fe448) Square(a *fe448) *fe448 { return e.Mul(a, a) }
+
+// Sets e = a^(2^n) and returns e.
+func (e *fe448) squareN(a *fe448, n int) *fe448 {
+	e.Set(a)
+	for i := 0; i < n; i++ {
+		e.Square(e)
+	}
+	return e
+}
+
+/*
+Sets e = 1/a = a^(p-2) and returns e. The inverse of 0 is 0. With
+x_k = a^(2^k - 1),
+
+	p - 2 = (2^223 - 1) * 2^225 + (2^222 - 1) * 4 + 1
+*/
+func (e *fe448) Invert(a *fe448) *fe448 {
+	x2, x3, x6, x12, x24, x48, x96, x192 := new(fe448), new(fe448), new(fe448), new(fe448),
+		new(fe448), new(fe448), new(fe448), new(fe448)
+	t := new(fe448)
+	x2.Mul(t.Square(a), a)                           // 2^2 - 1
+	x3.Mul(t.Square(x2), a)                          // 2^3 - 1
+	x6.Mul(t.squareN(x3, 3), x3)                     // 2^6 - 1
+	x12.Mul(t.squareN(x6, 6), x6)                    // 2^12 - 1
+	x24.Mul(t.squareN(x12, 12), x12)                 // 2^24 - 1
+	x48.Mul(t.squareN(x24, 24), x24)                 // 2^48 - 1
+	x96.Mul(t.squareN(x48, 48), x48)                 // 2^96 - 1
+	x192.Mul(t.squareN(x96, 96), x96)                // 2^192 - 1
+	x216 := new(fe448).Mul(t.squareN(x192, 24), x24) // 2^216 - 1
+	x222 := new(fe448).Mul(t.squareN(x216, 6), x6)   // 2^222 - 1
+	x223 := new(fe448).Mul(t.Square(x222), a)        // 2^223 - 1
+	r := new(fe448).Mul(t.squareN(x223, 225), new(fe448).squareN(x222, 2))
+	return e.Mul(r, a)
+}
+
+/*
+Reduces e into the canonical range [0, p). Carry passes bring every limb
+to its nominal width with the value below 2^448 < 2p, and p is subtracted
+by a masked conditional selection: e >= p exactly when e + 2^224 + 1
+reaches 2^448, in which case e - p is that sum less 2^448.
+*/
+func (e *fe448) reduce() *fe448 {
+	for pass := 0; pass < 3; pass++ {
+		e.carry()
+	}
+	var w fe448
+	c := uint64(0)
+	for i := 0; i < fe448Limbs-1; i++ {
+		w[i] = e[i] + fe448PComplement[i] + c
+		c = w[i] >> 56
+		w[i] &= fe448Mask
+	}
+	w[7] = e[7] + c
+	geP := w[7] >> 56
+	w[7] &= fe448Mask
+	return e.Select(&w, e, int(geP))
+}
+
+// Sets e = a if cond == 1, or e = b if cond == 0, and returns e.
+func (e *fe448) Select(a, b *fe448, cond int) *fe448 {
+	mask := -uint64(cond & 1)
+	for i := range e {
+		e[i] = (a[i] & mask) | (b[i] &^ mask)
+	}
+	return e
+}
+
+// Swaps a and b if cond == 1 and leaves them unchanged if cond == 0.
+func fe448Swap(a, b *fe448, cond int) {
+	mask := -uint64(cond & 1)
+	for i := range a {
+		t := mask & (a[i] ^ b[i])
+		a[i] ^= t
+		b[i] ^= t
+	}
+}
+
+// Returns 1 if a == b (mod p) and 0 otherwise.
+func (e *fe448) Equal(b *fe448) int {
+	return subtle.ConstantTimeCompare(e.Bytes(), b.Bytes())
+}
+
+// Returns 1 if e == 0 (mod p) and 0 otherwise.
+func (e *fe448) IsZero() int {
+	return e.Equal(&fe448{})
+}
+
+// Returns the canonical 56 byte big-endian encoding of e.
+func (e *fe448) Bytes() []byte {
+	t := *e
+	t.reduce()
+	out := make([]byte, fe448Bytes)
+	for i := 0; i < fe448Bytes; i++ {
+		out[fe448Bytes-1-i] = byte(t[i/7] >> (8 * (i % 7)))
+	}
+	return out
+}
+
+// Sets e = x mod p for an arbitrary integer x and returns e. For use at API boundaries.
+func (e *fe448) SetBigInt(x *big.Int) *fe448 {
+	buf := make([]byte, fe448Bytes)
+	new(big.Int).Mod(x, &ed448P).FillBytes(buf)
+	*e = fe448{}
+	for i := 0; i < fe448Bytes; i++ {
+		e[i/7] |= uint64(buf[fe448Bytes-1-i]) << (8 * (i % 7))
+	}
+	return e
+}
+
+// Returns the canonical value of e as a big.Int. For use at API boundaries.
+func (e *fe448) BigInt() *big.Int {
+	return new(big.Int).SetBytes(e.Bytes())
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// Edge values plus random elements of GF(2^448 - 2^224 - 1), as big.Int reduced mod p.
+func field448TestValues(n int) []*big.Int {
+	rng := rand.New(rand.NewSource(448))
+	one := big.NewInt(1)
+	vals := []*big.Int{
+		big.NewInt(0), big.NewInt(1), big.NewInt(2), big.NewInt(39081),
+		new(big.Int).Sub(&ed448P, one), new(big.Int).Sub(&ed448P, big.NewInt(2)),
+		new(big.Int).Lsh(one, 224), new(big.Int).Sub(new(big.Int).Lsh(one, 224), one),
+		new(big.Int).Lsh(one, 447), new(big.Int).Sub(&ed448P, new(big.Int).Lsh(one, 224)),
+	}
+	for i := 0; i < n; i++ {
+		vals = append(vals, new(big.Int).Rand(rng, &ed448P))
+	}
+	return vals
+}
+
+func TestField448MatchesBigInt(t *testing.T) {
+	vals := field448TestValues(40)
+	for _, a := range vals {
+		for _, b := range vals {
+			fa, fb := new(fe448).SetBigInt(a), new(fe448).SetBigInt(b)
+			check := func(op string, got *fe448, want *big.Int) {
+				t.Helper()
+				if got.BigInt().Cmp(want.Mod(want, &ed448P)) != 0 {
+					t.Fatalf("%s(%x, %x) = %x, want %x", op, a, b, got.BigInt(), want)
+				}
+			}
+			check("add", new(fe448).Add(fa, fb), new(big.Int).Add(a, b))
+			check("sub", new(fe448).Sub(fa, fb), new(big.Int).Sub(a, b))
+			check("mul", new(fe448).Mul(fa, fb), new(big.Int).Mul(a, b))
+		}
+		fa := new(fe448).SetBigInt(a)
+		if got, want := new(fe448).Square(fa).BigInt(), new(big.Int).Exp(a, big.NewInt(2), &ed448P); got.Cmp(want) != 0 {
+			t.Fatalf("square(%x) = %x, want %x", a, got, want)
+		}
+		want := new(big.Int).ModInverse(a, &ed448P)
+		if a.Sign() == 0 {
+			want = new(big.Int)
+		}
+		if got := new(fe448).Invert(fa).BigInt(); got.Cmp(want) != 0 {
+			t.Fatalf("invert(%x) = %x, want %x", a, got, want)
+		}
+	}
+}
+
+// Chains of operations leave limbs loosely reduced; results must stay exact.
+func TestField448LongChains(t *testing.T) {
+	vals := field448TestValues(2)
+	a, b := vals[len(vals)-2], vals[len(vals)-1]
+	fa, fb := new(fe448).SetBigInt(a), new(fe448).SetBigInt(b)
+	want := new(big.Int).Set(a)
+	got := new(fe448).Set(fa)
+	for i := 0; i < 200; i++ {
+		got.Add(got, got).Sub(got, fb).Mul(got, fa)
+		want.Mul(want.Sub(want.Add(want, want), b), a).Mod(want, &ed448P)
+	}
+	if got.BigInt().Cmp(want) != 0 {
+		t.Fatalf("chained result %x, want %x", got.BigInt(), want)
+	}
+}
+
+func TestField448Encoding(t *testing.T) {
+	for _, a := range field448TestValues(20) {
+		buf := make([]byte, fe448Bytes)
+		a.FillBytes(buf)
+		if got := new(fe448).SetBigInt(a).Bytes(); !bytes.Equal(got, buf) {
+			t.Fatalf("Bytes(%x) = %x", a, got)
+		}
+	}
+	// loosely reduced representations of p and of p + 1 encode as 0 and 1
+	p := fe448FourP
+	for i := range p {
+		p[i] /= 4
+	}
+	if p.IsZero() != 1 {
+		t.Error("p did not reduce to zero")
+	}
+	p[0]++
+	if p.Equal(fe448FromUint64(1)) != 1 {
+		t.Error("p + 1 did not reduce to one")
+	}
+}
+
+func TestField448SelectAndSwap(t *testing.T) {
+	a, b := fe448FromUint64(1), fe448FromUint64(2)
+	if new(fe448).Select(a, b, 1).Equal(a) != 1 || new(fe448).Select(a, b, 0).Equal(b) != 1 {
+		t.Error("Select picked the wrong input")
+	}
+	fe448Swap(a, b, 0)
+	if a.Equal(fe448FromUint64(1)) != 1 {
+		t.Error("fe448Swap swapped with cond = 0")
+	}
+	fe448Swap(a, b, 1)
+	if a.Equal(fe448FromUint64(2)) != 1 || b.Equal(fe448FromUint64(1)) != 1 {
+		t.Error("fe448Swap did not swap with cond = 1")
+	}
+}
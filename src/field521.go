@@ -0,0 +1,302 @@
+package main
+
+import (
+	"crypto/subtle"
+	"math/big"
+	"math/bits"
+)
+
+/*
+Constant-time arithmetic in GF(p) for the Mersenne prime p = 2^521 - 1.
+
+An element is held in nine unsigned 64-bit limbs in radix 2^58, so that
+value = l[0] + l[1]*2^58 + ... + l[8]*2^464. When fully reduced the first
+eight limbs hold 58 bits and the top limb 57 bits. Between operations limbs
+may carry a few extra bits of headroom. Reduction uses 2^521 = 1 (mod p).
+No operation branches on, or indexes memory by, the value of an element.
+*/
+type fieldElement [9]uint64
+
+const (
+	feLimbs    = 9
+	feBits     = 521
+	feBytes    = 66
+	feMask58   = 1<<58 - 1
+	feMask57   = 1<<57 - 1
+	feTopShift = 57
+)
+
+// 4p, added before subtraction so that limbs never underflow
+var fe4P = fieldElement{
+	4 * feMask58, 4 * feMask58, 4 * feMask58, 4 * feMask58,
+	4 * feMask58, 4 * feMask58, 4 * feMask58, 4 * feMask58, 4 * feMask57,
+}
+
+// Returns a new element set to the small integer v.
+func feFromUint64(v uint64) *fieldElement {
+	e := &fieldElement{}
+	e[0] = v & feMask58
+	e[1] = v >> 58
+	return e
+}
+
+// Sets e = a and returns e.
+func (e *fieldElement) Set(a *fieldElement) *fieldElement {
+	*e = *a
+	return e
+}
+
+/*
+Propagates carries so that each limb fits its width plus at most one bit.
+The carry out of the top limb wraps to limb 0 since 2^521 = 1 (mod p).
+*/
+func (e *fieldElement) carry() *fieldElement {
+	for i := 0; i < feLimbs-1; i++ {
+		e[i+1] += e[i] >> 58
+		e[i] &= feMask58
+	}
+	c := e[8] >> feTopShift
+	e[8] &= feMask57
+	e[0] += c
+	e[1] += e[0] >> 58
+	e[0] &= feMask58
+	return e
+}
+
+// Sets e = a + b and returns e.
+func (e *fieldElement) Add(a, b *fieldElement) *fieldElement {
+	for i := range e {
+		e[i] = a[i] + b[i]
+	}
+	return e.carry()
+}
+
+// Sets e = a - b and returns e.
+func (e *fieldElement) Sub(a, b *fieldElement) *fieldElement {
+	for i := range e {
+		e[i] = a[i] + fe4P[i] - b[i]
+	}
+	return e.carry()
+}
+
+// Sets e = -a and returns e.
+func (e *fieldElement) Neg(a *fieldElement) *fieldElement {
+	return e.Sub(&fieldElement{}, a)
+}
+
+// 128-bit accumulator used by Mul.
+type uint128 struct{ hi, lo uint64 }
+
+func (u *uint128) addMul(a, b uint64) {
+	hi, lo := bits.Mul64(a, b)
+	var c uint64
+	u.lo, c = bits.Add64(u.lo, lo, 0)
+	u.hi += hi + c
+}
+
+func (u *uint128) add(v uint128) {
+	var c uint64
+	u.lo, c = bits.Add64(u.lo, v.lo, 0)
+	u.hi += v.hi + c
+}
+
+func (u uint128) shr(n uint) uint128 {
+	return uint128{hi: u.hi >> n, lo: u.lo>>n | u.hi<<(64-n)}
+}
+
+/*
+Sets e = a * b and returns e. Schoolbook multiplication into nine 128-bit
+columns. A product a[i]*b[j] with i + j >= 9 has weight 2^(58(i+j-9)) * 2^522,
+and as 2^522 = 2 (mod p) it is folded into column i + j - 9 doubled.
+*/
+func (e *fieldElement) Mul(a, b *fieldElement) *fieldElement {
+	var t [feLimbs]uint128
+	var b2 [feLimbs]uint64
+	for i := range b {
+		b2[i] = b[i] << 1
+	}
+	for i := 0; i < feLimbs; i++ {
+		for j := 0; j < feLimbs; j++ {
+			if k := i + j; k < feLimbs {
+				t[k].addMul(a[i], b[j])
+			} else {
+				t[k-feLimbs].addMul(a[i], b2[j])
+			}
+		}
+	}
+	for i := 0; i < feLimbs-1; i++ {
+		t[i+1].add(t[i].shr(58))
+		e[i] = t[i].lo & feMask58
+	}
+	e[8] = t[8].lo & feMask57
+	c := t[8].shr(feTopShift)
+	c.add(uint128{lo: e[0]})
+	e[0] = c.lo & feMask58
+	e[1] += c.shr(58).lo
+	return e.carry()
+}
+
+// Sets e = a^2 and returns e.
+func (e *fieldElement) Square(a *fieldElement) *fieldElement { return e.Mul(a, a) }
+
+// Sets e = a^(2^n) and returns e.
+func (e *fieldElement) squareN(a *fieldElement, n int) *fieldElement {
+	e.Set(a)
+	for i := 0; i < n; i++ {
+		e.Square(e)
+	}
+	return e
+}
+
+// Returns a^(2^519 - 1), the common prefix of inversion and square roots.
+func pow2e519m1(a *fieldElement) *fieldElement {
+	x2, x4, x8, x16, x32, x64, x128, x256, x512 := new(fieldElement), new(fieldElement), new(fieldElement),
+		new(fieldElement), new(fieldElement), new(fieldElement), new(fieldElement), new(fieldElement), new(fieldElement)
+	t := new(fieldElement)
+	x2.Mul(t.Square(a), a)                             // 2^2 - 1
+	x4.Mul(t.squareN(x2, 2), x2)                       // 2^4 - 1
+	x8.Mul(t.squareN(x4, 4), x4)                       // 2^8 - 1
+	x16.Mul(t.squareN(x8, 8), x8)                      // 2^16 - 1
+	x32.Mul(t.squareN(x16, 16), x16)                   // 2^32 - 1
+	x64.Mul(t.squareN(x32, 32), x32)                   // 2^64 - 1
+	x128.Mul(t.squareN(x64, 64), x64)                  // 2^128 - 1
+	x256.Mul(t.squareN(x128, 128), x128)               // 2^256 - 1
+	x512.Mul(t.squareN(x256, 256), x256)               // 2^512 - 1
+	r := new(fieldElement).Mul(t.squareN(x512, 4), x4) // 2^516 - 1
+	r.Mul(t.squareN(r, 2), x2)                         // 2^518 - 1
+	return r.Mul(t.Square(r), a)                       // 2^519 - 1
+}
+
+// Sets e = 1/a = a^(p-2) = a^(4(2^519 - 1) + 1) and returns e. The inverse of 0 is 0.
+func (e *fieldElement) Invert(a *fieldElement) *fieldElement {
+	t := new(fieldElement).squareN(pow2e519m1(a), 2)
+	return e.Mul(t, a)
+}
+
+/*
+Sets e to a square root of a and returns e and 1, or returns e and 0 if
+a is not a square. As p = 3 (mod 4) a root is a^((p+1)/4) = a^(2^519).
+*/
+func (e *fieldElement) Sqrt(a *fieldElement) (*fieldElement, int) {
+	r := new(fieldElement).squareN(a, 519)
+	ok := new(fieldElement).Square(r).Equal(a)
+	e.Set(r)
+	return e, ok
+}
+
+/*
+Reduces e into the canonical range [0, p). Two full carry passes bring the
+value into [0, p] with every limb at its nominal width, and p itself is
+mapped to 0 by a masked conditional subtraction.
+*/
+func (e *fieldElement) reduce() *fieldElement {
+	for pass := 0; pass < 2; pass++ {
+		for i := 0; i < feLimbs-1; i++ {
+			e[i+1] += e[i] >> 58
+			e[i] &= feMask58
+		}
+		c := e[8] >> feTopShift
+		e[8] &= feMask57
+		e[0] += c
+	}
+	// w = e + 1 overflows 2^521 exactly when e = p, in which case e - p = w - 2^521
+	var w fieldElement
+	c := uint64(1)
+	for i := 0; i < feLimbs-1; i++ {
+		w[i] = e[i] + c
+		c = w[i] >> 58
+		w[i] &= feMask58
+	}
+	w[8] = e[8] + c
+	isP := w[8] >> feTopShift
+	w[8] &= feMask57
+	e.Select(&w, e, int(isP))
+	return e
+}
+
+// Sets e = a if cond == 1, or e = b if cond == 0, and returns e.
+func (e *fieldElement) Select(a, b *fieldElement, cond int) *fieldElement {
+	mask := -uint64(cond & 1)
+	for i := range e {
+		e[i] = (a[i] & mask) | (b[i] &^ mask)
+	}
+	return e
+}
+
+// Swaps a and b if cond == 1 and leaves them unchanged if cond == 0.
+func feSwap(a, b *fieldElement, cond int) {
+	mask := -uint64(cond & 1)
+	for i := range a {
+		t := mask & (a[i] ^ b[i])
+		a[i] ^= t
+		b[i] ^= t
+	}
+}
+
+// Returns 1 if a == b (mod p) and 0 otherwise.
+func (e *fieldElement) Equal(b *fieldElement) int {
+	return subtle.ConstantTimeCompare(e.Bytes(), b.Bytes())
+}
+
+// Returns 1 if e == 0 (mod p) and 0 otherwise.
+func (e *fieldElement) IsZero() int {
+	return e.Equal(&fieldElement{})
+}
+
+// Returns the least significant bit of the canonical value of e.
+func (e *fieldElement) lsb() int {
+	t := *e
+	return int(t.reduce()[0] & 1)
+}
+
+// Returns the canonical 66 byte big-endian encoding of e.
+func (e *fieldElement) Bytes() []byte {
+	t := *e
+	t.reduce()
+	out := make([]byte, feBytes)
+	for i := 0; i < feBits; i++ {
+		bit := byte(t[i/58]>>(i%58)) & 1
+		out[feBytes-1-i/8] |= bit << (i % 8)
+	}
+	return out
+}
+
+/*
+Sets e from a 66 byte big-endian encoding and returns e and 1, or returns
+e and 0 if the encoding is not canonical, i.e. the value is not below p.
+*/
+func (e *fieldElement) SetBytes(b []byte) (*fieldElement, int) {
+	if len(b) != feBytes {
+		return e, 0
+	}
+	*e = fieldElement{}
+	for i := 0; i < feBits; i++ {
+		bit := uint64(b[feBytes-1-i/8]>>(i%8)) & 1
+		e[i/58] |= bit << (i % 58)
+	}
+	high := b[0] >> 1 // bits 521 to 527 must be zero
+	canonical := subtle.ConstantTimeByteEq(high, 0) & (1 - e.isP())
+	return e, canonical
+}
+
+// Returns 1 if the limbs of e spell out p exactly, i.e. every bit set.
+func (e *fieldElement) isP() int {
+	acc := e[8] ^ feMask57
+	for i := 0; i < feLimbs-1; i++ {
+		acc |= e[i] ^ feMask58
+	}
+	return subtle.ConstantTimeEq(int32(acc>>32|acc&0xffffffff), 0)
+}
+
+// Sets e = x mod p for an arbitrary integer x and returns e. For use at API boundaries.
+func (e *fieldElement) SetBigInt(x *big.Int) *fieldElement {
+	buf := make([]byte, feBytes)
+	new(big.Int).Mod(x, &e521P).FillBytes(buf)
+	e.SetBytes(buf)
+	return e
+}
+
+// Returns the canonical value of e as a big.Int. For use at API boundaries.
+func (e *fieldElement) BigInt() *big.Int {
+	return new(big.Int).SetBytes(e.Bytes())
+}
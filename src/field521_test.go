@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// Edge values plus random elements, as big.Int reduced mod p.
+func fieldTestValues(n int) []*big.Int {
+	rng := rand.New(rand.NewSource(521))
+	one := big.NewInt(1)
+	vals := []*big.Int{
+		big.NewInt(0), big.NewInt(1), big.NewInt(2), big.NewInt(376014),
+		new(big.Int).Sub(&e521P, one), new(big.Int).Sub(&e521P, big.NewInt(2)),
+		new(big.Int).Lsh(one, 520), new(big.Int).Sub(new(big.Int).Lsh(one, 464), one),
+	}
+	for i := 0; i < n; i++ {
+		vals = append(vals, new(big.Int).Rand(rng, &e521P))
+	}
+	return vals
+}
+
+func TestFieldMatchesBigInt(t *testing.T) {
+	vals := fieldTestValues(40)
+	for _, a := range vals {
+		for _, b := range vals {
+			fa, fb := feFromBig(a), feFromBig(b)
+			check := func(op string, got *fieldElement, want *big.Int) {
+				t.Helper()
+				if got.BigInt().Cmp(want.Mod(want, &e521P)) != 0 {
+					t.Fatalf("%s(%x, %x) = %x, want %x", op, a, b, got.BigInt(), want)
+				}
+			}
+			check("add", new(fieldElement).Add(fa, fb), new(big.Int).Add(a, b))
+			check("sub", new(fieldElement).Sub(fa, fb), new(big.Int).Sub(a, b))
+			check("mul", new(fieldElement).Mul(fa, fb), new(big.Int).Mul(a, b))
+		}
+		fa := feFromBig(a)
+		if got, want := new(fieldElement).Square(fa).BigInt(), new(big.Int).Exp(a, big.NewInt(2), &e521P); got.Cmp(want) != 0 {
+			t.Fatalf("square(%x) = %x, want %x", a, got, want)
+		}
+		if a.Sign() != 0 {
+			want := new(big.Int).ModInverse(a, &e521P)
+			if got := new(fieldElement).Invert(fa).BigInt(); got.Cmp(want) != 0 {
+				t.Fatalf("invert(%x) = %x, want %x", a, got, want)
+			}
+		}
+		r, ok := new(fieldElement).Sqrt(fa)
+		if isSquare := big.Jacobi(a, &e521P) >= 0; isSquare != (ok == 1) {
+			t.Fatalf("sqrt(%x) reported ok = %d", a, ok)
+		}
+		if ok == 1 && new(fieldElement).Square(r).Equal(fa) != 1 {
+			t.Fatalf("sqrt(%x) = %x is not a root", a, r.BigInt())
+		}
+	}
+}
+
+// Chains of operations leave limbs loosely reduced; results must stay exact.
+func TestFieldLongChains(t *testing.T) {
+	a, b := fieldTestValues(2)[8], fieldTestValues(2)[9]
+	fa, fb := feFromBig(a), feFromBig(b)
+	want := new(big.Int).Set(a)
+	got := new(fieldElement).Set(fa)
+	for i := 0; i < 200; i++ {
+		got.Add(got, got).Sub(got, fb).Mul(got, fa)
+		want.Mul(want.Sub(want.Add(want, want), b), a).Mod(want, &e521P)
+	}
+	if got.BigInt().Cmp(want) != 0 {
+		t.Fatalf("chained result %x, want %x", got.BigInt(), want)
+	}
+}
+
+func TestFieldEncoding(t *testing.T) {
+	for _, a := range fieldTestValues(20) {
+		buf := make([]byte, feBytes)
+		a.FillBytes(buf)
+		if got := feFromBig(a).Bytes(); !bytes.Equal(got, buf) {
+			t.Fatalf("Bytes(%x) = %x", a, got)
+		}
+		if e, ok := new(fieldElement).SetBytes(buf); ok != 1 || e.BigInt().Cmp(a) != 0 {
+			t.Fatalf("SetBytes(%x) = %x, %d", buf, e.BigInt(), ok)
+		}
+	}
+	// p, 2^521 and a set high bit are all non-canonical
+	for _, bad := range []*big.Int{&e521P, new(big.Int).Lsh(big.NewInt(1), 521), new(big.Int).Lsh(big.NewInt(1), 527)} {
+		buf := make([]byte, feBytes)
+		bad.FillBytes(buf)
+		if _, ok := new(fieldElement).SetBytes(buf); ok != 0 {
+			t.Errorf("SetBytes accepted non-canonical %x", bad)
+		}
+	}
+	// loosely reduced representations of p encode as zero
+	p := fe4P
+	for i := range p {
+		p[i] /= 4
+	}
+	if p.IsZero() != 1 || p.lsb() != 0 {
+		t.Error("p did not reduce to zero")
+	}
+}
+
+func TestFieldSelectAndSwap(t *testing.T) {
+	a, b := feFromUint64(1), feFromUint64(2)
+	if new(fieldElement).Select(a, b, 1).Equal(a) != 1 || new(fieldElement).Select(a, b, 0).Equal(b) != 1 {
+		t.Error("Select picked the wrong input")
+	}
+	feSwap(a, b, 0)
+	if a.Equal(feFromUint64(1)) != 1 {
+		t.Error("feSwap swapped with cond = 0")
+	}
+	feSwap(a, b, 1)
+	if a.Equal(feFromUint64(2)) != 1 || b.Equal(feFromUint64(1)) != 1 {
+		t.Error("feSwap did not swap with cond = 1")
+	}
+}
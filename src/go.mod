@@ -6,5 +6,5 @@ require github.com/lukechampine/fastxor v0.0.0-20210322201628-b664bed5a5cc
 
 require (
 	github.com/gotk3/gotk3 v0.6.1
-	golang.org/x/sys v0.4.0 // indirect
+	golang.org/x/sys v0.4.0
 )
@@ -1,7 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
 	"os"
 
 	"github.com/gotk3/gotk3/gdk"
@@ -9,6 +15,12 @@ import (
 	"github.com/gotk3/gotk3/gtk"
 )
 
+// returned when no stored private key is able to decrypt a cryptogram
+var errNoPrivateKey = errors.New("no matching private key")
+
+// returned when no stored key matches the fingerprint embedded in a signature
+var errUnknownSigner = errors.New("unknown signer")
+
 type KeyTable struct {
 	treeview           *gtk.TreeView       // Displays list of keys currently imported into context
 	store              *gtk.ListStore      // Contains a list of keys
@@ -24,31 +36,206 @@ type KeyObj struct {
 	PUBLIC keys are used only for encryptions, while PRIVATE keys can
 	encrypt or decrypt.
 	*/
-	PubKeyX     string `json:"PubKeyX"`     //big.Int value representing E521 X coordinate
-	PubKeyY     string `json:"PubKeyY"`     //big.Int value representing E521 X coordinate
-	PrivKey     string `json:"PrivKey"`     //big.Int value representing secret scalar, nil if KeyType is PUBLIC
-	DateCreated string `json:"DateCreated"` //Date key was generated
-	Signature   string `json:"Signature"`   //Nil unless PUBLIC. Signs 128 bit SHA3 hash of this KeyObj
+	Curve       string `json:"Curve,omitempty"`   //name of the key's curve, E521 if empty
+	PubKey      string `json:"PubKey,omitempty"`  //hex of the compressed public key
+	PubKeyX     string `json:"PubKeyX,omitempty"` //legacy big.Int value representing E521 X coordinate
+	PubKeyY     string `json:"PubKeyY,omitempty"` //legacy big.Int value representing E521 Y coordinate
+	PrivKey     string `json:"PrivKey"`           //big.Int value representing secret scalar, nil if KeyType is PUBLIC
+	DateCreated string `json:"DateCreated"`       //Date key was generated
+	Signature   string `json:"Signature"`         //Nil unless PUBLIC. Signs 128 bit SHA3 hash of this KeyObj
+}
+
+/*
+Generates a random key ID by absorbing 200 bytes from rng followed by
+suffix into the sponge.
+
+	rng: source of randomness
+	suffix: domain separating suffix appended to the random bytes
+	return: 96 character hex ID
+*/
+func newKeyID(rng io.Reader, suffix []byte) (string, error) {
+	r, err := readRandomBytes(rng, 200)
+	if err != nil {
+		return "", err
+	}
+	r = append(r, suffix...)
+	return hex.EncodeToString(SpongeSqueeze(SpongeAbsorb(&r, 256), 48, 136)), nil
+}
+
+// Reads the public key of a KeyObj without checking its subgroup. E521 keys
+// exported before compressed encodings were introduced carry decimal coordinates.
+func (key *KeyObj) publicPoint() (Point, error) {
+	curve, err := curveByName(key.Curve)
+	if err != nil {
+		return nil, err
+	}
+	if key.PubKey != "" || curve.ID() != CurveE521 {
+		enc, err := hex.DecodeString(key.PubKey)
+		if err != nil {
+			return nil, wrapErr(ErrMalformed, err)
+		}
+		return curve.DecodePoint(enc)
+	}
+	x, okX := new(big.Int).SetString(key.PubKeyX, 10)
+	y, okY := new(big.Int).SetString(key.PubKeyY, 10)
+	if !okX || !okY {
+		return nil, wrapErr(ErrMalformed, errors.New("unreadable public key"))
+	}
+	return newE521Checked(x, y)
+}
+
+// Reconstructs the public key point of a KeyObj, rejecting invalid points.
+func (key *KeyObj) publicKey() (Point, error) {
+	V, err := key.publicPoint()
+	if err != nil {
+		return nil, err
+	}
+	if err := V.validate(); err != nil {
+		return nil, err
+	}
+	return V, nil
+}
+
+// Fingerprint of the public key of a KeyObj, or nil if it cannot be read.
+func (key *KeyObj) fingerprint() []byte {
+	V, err := key.publicPoint()
+	if err != nil {
+		return nil
+	}
+	return keyFingerprint(V)
+}
+
+/*
+Lists the PRIVATE keys that may decrypt a cryptogram for recipient fp.
+If fp is empty the recipient is hidden and every private key is returned.
+*/
+func (kt *KeyTable) decryptionKeys(fp []byte) []KeyObj {
+	var keys []KeyObj
+	for _, key := range kt.keyList {
+		if key.KeyType != "PRIVATE" || key.PrivKey == "" {
+			continue
+		}
+		if len(fp) == 0 || bytes.Equal(key.fingerprint(), fp) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Finds the stored key with fingerprint fp, preferring the lowest Id if several match.
+func (kt *KeyTable) keyByFingerprint(fp []byte) *KeyObj {
+	var found *KeyObj
+	for _, key := range kt.keyList {
+		if bytes.Equal(key.fingerprint(), fp) && (found == nil || key.Id < found.Id) {
+			key := key
+			found = &key
+		}
+	}
+	return found
+}
+
+/*
+Selects the key to verify a signature with. Signatures that name their
+signer by fingerprint are checked against the matching stored key; older
+signatures without one fall back to the selected key.
+
+	fp: signer fingerprint embedded in the signature, may be empty
+	fallback: key selected by the user, may be nil
+	return: the verifying key, or an error wrapping errUnknownSigner
+*/
+func (kt *KeyTable) signerKey(fp []byte, fallback *KeyObj) (*KeyObj, error) {
+	if len(fp) == 0 {
+		if fallback == nil {
+			return nil, fmt.Errorf("%w: signature does not name its signer and no key is selected", errUnknownSigner)
+		}
+		return fallback, nil
+	}
+	if key := kt.keyByFingerprint(fp); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w %x", errUnknownSigner, fp)
+}
+
+/*
+Decrypts an EC cryptogram with the stored private keys, selecting the key
+named by the embedded recipient fingerprint, or trying every private key
+when the recipient is hidden.
+
+	cg: cryptogram to decrypt
+	return: plaintext and the key that decrypted it, or errNoPrivateKey if
+	no stored key is able to decrypt the cryptogram
+*/
+func (kt *KeyTable) decrypt(cg *ECCryptogram, opts ...cryptOption) ([]byte, *KeyObj, error) {
+	for _, key := range kt.decryptionKeys(cg.R) {
+		curve, err := curveByName(key.Curve)
+		if err != nil || curve.ID() != cg.Curve {
+			continue
+		}
+		s, ok := new(big.Int).SetString(key.PrivKey, 10)
+		if !ok {
+			continue
+		}
+		m, err := decryptWithPrivateKey(curve.scalars().zero().SetBigInt(s), cg, opts...)
+		if errors.Is(err, ErrAuthFailed) {
+			continue
+		}
+		return m, &key, err
+	}
+	return nil, nil, errNoPrivateKey
 }
 
 // Converts JSON to KeyObj. Returns error if conversion is unsuccessful.
 func (kt *KeyTable) JsonToKey(ctx *WindowCtx, filename string) error {
-	data, _ := os.ReadFile(filename)
-	var result = KeyObj{}
-	err := json.Unmarshal(data, &result)
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	key, err := parseKeyJSON(data)
 	if err != nil {
 		return err
 	}
-	kt.importKey(ctx, result)
+	kt.importKey(ctx, *key)
 	return nil
 }
 
+/*
+Parses and validates an exported key. The public key must decode to a valid
+point of the key's curve, and a private scalar, if present, must be a
+non-negative decimal integer s with s*G equal to the public key.
+*/
+func parseKeyJSON(data []byte) (*KeyObj, error) {
+	var key KeyObj
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, wrapErr(ErrMalformed, err)
+	}
+	V, err := key.publicKey()
+	if err != nil {
+		return nil, err
+	}
+	if key.PrivKey != "" {
+		s, ok := new(big.Int).SetString(key.PrivKey, 10)
+		if !ok || s.Sign() < 0 {
+			return nil, wrapErr(ErrMalformed, errors.New("unreadable private key"))
+		}
+		curve, err := curveByName(key.Curve)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.ScalarBaseMult(s).Equal(V) {
+			return nil, wrapErr(ErrMalformed, errors.New("private key does not match the public key"))
+		}
+	}
+	return &key, nil
+}
+
 // Converts a key to JSON format
 func KeyToJSON(key *KeyObj) ([]byte, error) {
 	u, err := json.Marshal(KeyObj{
 		Id:          key.Id,
 		Owner:       key.Owner,
 		KeyType:     key.KeyType,
+		Curve:       key.Curve,
+		PubKey:      key.PubKey,
 		PubKeyX:     key.PubKeyX,
 		PubKeyY:     key.PubKeyY,
 		PrivKey:     key.PrivKey,
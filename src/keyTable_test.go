@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestParseKeyJSON(t *testing.T) {
+	key := KeyObj{Id: "id", KeyType: "PRIVATE"}
+	generateKeyPair(&key, "pw", "owner")
+	data, _ := KeyToJSON(&key)
+	parsed, err := parseKeyJSON(data)
+	if err != nil || *parsed != key {
+		t.Fatalf("exported key did not parse back: %v", err)
+	}
+	for name, bad := range map[string]string{
+		"not JSON":        `{"Id":`,
+		"no public key":   `{"Id":"id"}`,
+		"bad hex":         `{"PubKey":"zz"}`,
+		"unknown curve":   `{"Curve":"P-256","PubKey":"00"}`,
+		"legacy garbage":  `{"PubKeyX":"4","PubKeyY":"x"}`,
+		"bad private key": `{"PubKey":"` + key.PubKey + `","PrivKey":"-5"}`,
+	} {
+		if _, err := parseKeyJSON([]byte(bad)); err == nil {
+			t.Errorf("%s: parsed without error", name)
+		}
+	}
+	other := KeyObj{Id: "other", KeyType: "PRIVATE"}
+	generateKeyPair(&other, "other", "owner")
+	for name, mismatched := range map[string]KeyObj{
+		"foreign private key": {Id: "id", KeyType: "PRIVATE", PubKey: key.PubKey, PrivKey: other.PrivKey},
+		"private key of zero": {Id: "id", KeyType: "PRIVATE", PubKey: key.PubKey, PrivKey: "0"},
+	} {
+		data, _ := KeyToJSON(&mismatched)
+		if _, err := parseKeyJSON(data); !errors.Is(err, ErrMalformed) {
+			t.Errorf("%s: got %v, want ErrMalformed", name, err)
+		}
+	}
+	offCurve := `{"PubKeyX":"4","PubKeyY":"4"}`
+	if _, err := parseKeyJSON([]byte(offCurve)); !errors.Is(err, ErrInvalidPoint) {
+		t.Errorf("off-curve legacy key: got %v, want ErrInvalidPoint", err)
+	}
+}
+
+func FuzzParseKeyJSON(f *testing.F) {
+	for _, curve := range []Curve{E521Curve, Ed448Curve} {
+		key := KeyObj{Id: "id", KeyType: "PRIVATE"}
+		generateKeyPairOn(curve, &key, "pw", "owner")
+		data, _ := KeyToJSON(&key)
+		f.Add(data)
+	}
+	Gx, Gy := E521GenPoint(0).affine()
+	f.Add([]byte(`{"Id":"legacy","KeyType":"PUBLIC","PubKeyX":"` + Gx.String() + `","PubKeyY":"` + Gy.String() + `"}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		key, err := parseKeyJSON(data)
+		if err != nil {
+			return
+		}
+		V, err := key.publicKey()
+		if err != nil {
+			t.Fatalf("accepted key has an invalid public key: %v", err)
+		}
+		if s, ok := new(big.Int).SetString(key.PrivKey, 10); ok {
+			if curve, _ := curveByName(key.Curve); !curve.ScalarBaseMult(s).Equal(V) {
+				t.Fatal("accepted private key does not match its public key")
+			}
+		}
+		key.fingerprint()
+	})
+}
+
+func TestKeyTableDecrypt(t *testing.T) {
+	alice := testKeyObj(t, E521Curve, "alice", "alice")
+	bob := testKeyObj(t, E521Curve, "bob", "bob")
+	carol := testKeyObj(t, Ed448Curve, "carol", "carol")
+	// a copy of bob's public key holding the wrong private scalar
+	stale := *bob
+	stale.Id, stale.PrivKey = "bob-stale", alice.PrivKey
+	kt := &KeyTable{keyList: map[string]KeyObj{"alice": *alice, "bob": *bob, "carol": *carol, "bob-stale": stale}}
+
+	V, _ := bob.publicKey()
+	msg := []byte("for bob")
+	encrypt := func(opts ...cryptOption) *ECCryptogram {
+		raw, err := encryptWithKey(V, &msg, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cg, _ := decodeECCryptogram(raw)
+		return cg
+	}
+	named, hidden := encrypt(), encrypt(withHiddenRecipient())
+	if len(named.R) != fingerprintSize || len(hidden.R) != 0 {
+		t.Fatalf("recipient fingerprints %x and %x", named.R, hidden.R)
+	}
+	if keys := kt.decryptionKeys(named.R); len(keys) != 2 {
+		t.Errorf("fingerprint selected %d keys, want bob and bob-stale", len(keys))
+	}
+	if keys := kt.decryptionKeys(nil); len(keys) != 4 {
+		t.Errorf("hidden recipient selected %d keys, want all 4", len(keys))
+	}
+	for name, cg := range map[string]*ECCryptogram{"named recipient": named, "hidden recipient": hidden} {
+		m, key, err := kt.decrypt(cg)
+		if err != nil || string(m) != string(msg) || key.Id != "bob" {
+			t.Errorf("%s: decrypted by %v: %v", name, key, err)
+		}
+	}
+
+	// a key that fails authentication is passed over, not reported
+	only := func(keys ...*KeyObj) *KeyTable {
+		kt := &KeyTable{keyList: map[string]KeyObj{}}
+		for _, key := range keys {
+			kt.keyList[key.Id] = *key
+		}
+		return kt
+	}
+	for name, c := range map[string]struct {
+		kt *KeyTable
+		cg *ECCryptogram
+	}{
+		"only a stale key":    {only(&stale), named},
+		"only other keys":     {only(alice, carol), hidden},
+		"public key only":     {only(&KeyObj{Id: "bob", KeyType: "PUBLIC", PubKey: bob.PubKey}), named},
+		"curve mismatch":      {kt, func() *ECCryptogram { cg := *named; cg.Curve = CurveEd448; return &cg }()},
+		"hidden, wrong curve": {only(carol), hidden},
+	} {
+		if m, key, err := c.kt.decrypt(c.cg); err != errNoPrivateKey || m != nil || key != nil {
+			t.Errorf("%s: got %v, want errNoPrivateKey", name, err)
+		}
+	}
+}
@@ -12,9 +12,9 @@ version 0.1
 
 import (
 	"bytes"
+	"crypto/subtle"
 	"encoding/hex"
 	"errors"
-	"fmt"
 	"math/big"
 	"time"
 )
@@ -23,22 +23,57 @@ type SymCryptogram struct {
 	Z []byte // optional Z public nonce for symmetric operations
 	C []byte // c represents the ciphertext of an encryption
 	T []byte // t is the authentication tag for the message
+	F byte   // flags describing transforms applied to the plaintext, see padding.go
 }
 
 type ECCryptogram struct {
-	Z_x big.Int // Z_x is the x coordinate of the public nonce
-	Z_y big.Int // Z_y is the y coordinate of the public nonce
-	Z   []byte  // optional Z public nonce for symmetric operations
-	C   []byte  // c represents the ciphertext of an encryption
-	T   []byte  // t is the authentication tag for the message
+	Z_x   big.Int // legacy x coordinate of the public nonce, unused when Z is set
+	Z_y   big.Int // legacy y coordinate of the public nonce, unused when Z is set
+	Z     []byte  // compressed encoding of the public nonce Z
+	C     []byte  // c represents the ciphertext of an encryption
+	T     []byte  // t is the authentication tag for the message
+	F     byte    // flags describing transforms applied to the plaintext, see padding.go
+	R     []byte  // fingerprint of the recipient public key, empty if hidden
+	Curve byte    // curve of the recipient key, see curve.go
+}
+
+// Decodes the public nonce Z, accepting the legacy E521 coordinate form.
+func (cg *ECCryptogram) nonce() (Point, error) {
+	curve, err := curveByID(cg.Curve)
+	if err != nil {
+		return nil, err
+	}
+	if len(cg.Z) == 0 && curve.ID() == CurveE521 {
+		return newE521Checked(&cg.Z_x, &cg.Z_y)
+	}
+	return curve.DecodePoint(cg.Z)
 }
 
 type Signature struct {
 	M []byte   // 	message that was signed
 	H *big.Int //	keyed hash of signed message
 	Z *big.Int //	public nonce
+	U []byte   //	compressed commitment U = k*G, required for batch verification
+	C byte     //	curve of the signing key, see curve.go
+	F []byte   //	fingerprint of the signing key, empty in signatures made before it was recorded
+	V byte     //	format version, see sigVersionLegacy and sigVersionCommitted
 }
 
+/*
+Signature format versions. Legacy signatures carry only (h, z). Committed
+signatures also record the commitment U and the signer fingerprint, both of
+which are required, and hash under a different customization string so
+that stripping U and F to pass one off as a legacy signature breaks it.
+Detached signatures (see detachedSignature.go) store (h, z) outside a
+Signature and have a challenge of their own; their version never appears
+in an encoded Signature.
+*/
+const (
+	sigVersionLegacy    = 0
+	sigVersionCommitted = 1
+	sigVersionDetached  = 2
+)
+
 /*
 SHA3-Keccak functionaility ref NIST FIPS 202.
 
@@ -121,44 +156,58 @@ func ComputeTaggedHash(pw, message []byte, S string) string {
 Encrypts a byte array m symmetrically under passphrase pw:
 
 	z <- Random(512)
+	m <- pad(compress(m)) if compression or padding is requested
 	(ke || ka) <- KMACXOF256(z || pw, “”, 1024, “S”)
 	c <- KMACXOF256(ke, “”, |m|, “SKE”) xor m
 	t <- KMACXOF256(ka, m, 512, “SKA”)
 	pw: symmetric encryption key, can be blank
 	message: message to encrypt
+	opts: optional settings such as withPadding, withCompression or withRand
 	return: symmetric cryptogram: (z, c, t)
 */
-func encryptWithPW(pw []byte, msg *[]byte) *[]byte {
+func encryptWithPW(pw []byte, msg *[]byte, opts ...cryptOption) (*[]byte, error) {
+
+	cfg := newCryptConfig(opts)
+	flags := cfg.flags()
 
-	z := generateRandomBytes(64)
+	z, err := readRandomBytes(cfg.rand, 64)
+	if err != nil {
+		return nil, err
+	}
+	m := cfg.encode(*msg)
 	tempKeka := append(z, []byte(pw)...)
 	ke_ka := KMACXOF256(&tempKeka, &[]byte{}, 1024, "S")
 	ke := ke_ka[:64]
 	ka := ke_ka[64:]
-	pW := KMACXOF256(&ke, &[]byte{}, len(*msg)*8, "SKE")
-	c := XorBytes(pW, *msg)
-	t := KMACXOF256(&ka, msg, 512, "SKA")
+	pW := KMACXOF256(&ke, &[]byte{}, len(m)*8, "SKE")
+	c := XorBytes(pW, m)
+	authData := authenticatedData(flags, m)
+	t := KMACXOF256(&ka, &authData, 512, "SKA")
 
 	//construct a cryptogram
-	result0 := SymCryptogram{Z: z, C: c, T: t}
-	result, _ := encodeSymmetricCryptogram(&result0)
-	return result
+	result0 := SymCryptogram{Z: z, C: c, T: t, F: flags}
+	return encodeSymmetricCryptogram(&result0)
 }
 
 /*
 Decrypts a symmetric cryptogram (z, c, t) under passphrase pw
 
-	SECURITY NOTE: ciphertext length == plaintext length
+	SECURITY NOTE: ciphertext length == plaintext length unless padded
 	(ke || ka) <- KMACXOF256(z || pw, “”, 1024, “S”)
 	m <- KMACXOF256(ke, “”, |c|, “SKE”) xor c
 	t’ <- KMACXOF256(ka, m, 512, “SKA”)
 	accept if, and only if, t’ = t
+	m <- decompress(unpad(m)) as recorded in the cryptogram flags
 	msg: cryptogram to decrypt, assumes valid format.
 	pw: decryption password, can be blank
+	opts: optional settings such as withMaxDecompressedSize
 	return: m, if and only if t` = t
 */
-func decryptWithPW(pw []byte, cg *SymCryptogram) (*[]byte, error) {
+func decryptWithPW(pw []byte, cg *SymCryptogram, opts ...cryptOption) (*[]byte, error) {
 
+	if err := checkFlags(cg.F); err != nil {
+		return nil, err
+	}
 	z := cg.Z
 	c := cg.C
 	t := cg.T
@@ -169,38 +218,79 @@ func decryptWithPW(pw []byte, cg *SymCryptogram) (*[]byte, error) {
 
 	pW := KMACXOF256(&ke, &[]byte{}, len(c)*8, "SKE")
 	m := XorBytes(c, pW)
-	tP := KMACXOF256(&ka, &m, 512, "SKA")
-	if bytes.Equal(t, tP) {
-		return &m, nil
-	} else {
-		return &m, errors.New("unable to decrypt")
+	authData := authenticatedData(cg.F, m)
+	tP := KMACXOF256(&ka, &authData, 512, "SKA")
+	if subtle.ConstantTimeCompare(t, tP) != 1 {
+		zeroize(m)
+		zeroize(authData)
+		return nil, ErrAuthFailed
+	}
+	result, err := newCryptConfig(opts).decode(m, cg.F)
+	if err != nil {
+		zeroize(m)
+		return nil, err
 	}
+	return &result, nil
+}
+
+/*
+Derives the (Schnorr/ECDHIES) private scalar on a curve from passphrase pw:
 
+	s <- KMACXOF256(pw, “”, 512, “K”); s <- 4s mod r
+*/
+func privateScalar(curve Curve, pw []byte) *Scalar {
+	f := curve.scalars()
+	K := KMACXOF256(&pw, &[]byte{}, 512, "K")
+	s := f.zero().SetUniformBytes(K)
+	zeroize(K)
+	return s.Mul(s, f.fromUint64(4))
 }
 
+/*
+Computes the fingerprint identifying a public key V. Fingerprints are
+embedded in cryptograms so recipients can locate their private key.
+
+	fp <- cSHAKE256(x || y, 256, “”, “KEYID”)
+	V: public key point
+	return: 32 byte fingerprint
+*/
+func keyFingerprint(V Point) []byte {
+	enc := V.MarshalUncompressed()
+	return cSHAKE256(&enc, 8*fingerprintSize, "", "KEYID")
+}
+
+// Length in bytes of a key fingerprint.
+const fingerprintSize = 32
+
 /*
 Generates a (Schnorr/ECDHIES) key pair from passphrase pw:
 
-	s <- KMACXOF256(pw, “”, 512, “K”); s <- 4s
+	s <- KMACXOF256(pw, “”, 512, “K”); s <- 4s mod r
 	V <- s*G
 
 	key pair: (s, V)
 	key: a pointer to an empty KeyObj to be populated with user data
 */
 func generateKeyPair(key *KeyObj, password, owner string) {
+	generateKeyPairOn(E521Curve, key, password, owner)
+}
+
+// Generates a key pair as generateKeyPair does, on the given curve.
+func generateKeyPairOn(curve Curve, key *KeyObj, password, owner string) {
 	pwBytes := []byte(password)
-	s := new(big.Int).SetBytes(KMACXOF256(&pwBytes, &[]byte{}, 512, "K"))
-	s = s.Mul(s, big.NewInt(4))
-	s = s.Mod(s, &E521IdPoint().n)
+	s := privateScalar(curve, pwBytes)
 
-	V := *E521GenPoint(0).SecMul(s)
+	V := curve.ScalarBaseMult(s.BigInt())
 	key.Owner = owner
-	key.PrivKey = s.String()
-	key.PubKeyX = V.x.String()
-	key.PubKeyY = V.y.String()
+	key.PrivKey = s.BigInt().String()
+	if curve.ID() != CurveE521 {
+		key.Curve = curve.Name()
+	}
+	pubKey, _ := V.MarshalBinary()
+	key.PubKey = hex.EncodeToString(pubKey)
 	key.DateCreated = time.Now().Format(time.RFC1123)
-	sigString := []byte(key.Owner + key.PubKeyX + key.PubKeyY + key.DateCreated)
-	signed, _ := signWithKey(pwBytes, &sigString)
+	sigString := []byte(key.Owner + key.PubKey + key.DateCreated)
+	signed, _ := signWithKeyOn(curve, pwBytes, &sigString)
 	sigHash := KMACXOF256(&pwBytes, signed, 512, "SIG")
 	key.Signature = hex.EncodeToString(sigHash)
 
@@ -210,119 +300,262 @@ func generateKeyPair(key *KeyObj, password, owner string) {
 Encrypts a byte array m under the (Schnorr/ECDHIES) public key V.
 Operates under Schnorr/ECDHIES principle in that shared symmetric key is
 exchanged with recipient. SECURITY NOTE: ciphertext length == plaintext length
+unless a padding scheme is requested.
 
-	k <- Random(512); k <- 4k
+	k <- Random(512); k <- 4k mod r
+	m <- pad(compress(m)) if compression or padding is requested
 	W <- k*V; Z <- k*G
 	(ke || ka) <- KMACXOF256(W x , “”, 1024, “P”)
 	c <- KMACXOF256(ke, “”, |m|, “PKE”) xor m
 	t <- KMACXOF256(ka, m, 512, “PKA”)
 	pubKey: X coordinate of public static key V, accepted as string
 	message: message of any length or format to encrypt
-	return: cryptogram: (Z, c, t) = Z||c||t
+	opts: optional settings such as withPadding, withCompression, withRand
+	or withHiddenRecipient
+	return: cryptogram: (Z, c, t) = Z||c||t, tagged with the fingerprint of V
+	and the curve of V
 */
-func encryptWithKey(pubKey *E521, message *[]byte) *[]byte {
+func encryptWithKey(pubKey Point, message *[]byte, opts ...cryptOption) (*[]byte, error) {
+
+	if err := pubKey.validate(); err != nil {
+		return nil, err
+	}
+	curve := pubKey.Curve()
+	f := curve.scalars()
+	cfg := newCryptConfig(opts)
+	flags := cfg.flags()
 
-	k := big.NewInt(0).SetBytes(generateRandomBytes(64))
-	k = k.Mul(k, big.NewInt(4))
-	k = k.Mod(k, &pubKey.n)
+	kBytes, err := readRandomBytes(cfg.rand, 64)
+	if err != nil {
+		return nil, err
+	}
+	m := cfg.encode(*message)
+
+	kS := f.zero().SetUniformBytes(kBytes)
+	zeroize(kBytes)
+	k := kS.Mul(kS, f.fromUint64(4)).BigInt()
 
-	W := pubKey.SecMul(k)
-	fmt.Println(W.x.String())
-	fmt.Println(W.y.String())
+	W := pubKey.ScalarMult(k)
 
-	Z := E521GenPoint(0).SecMul(k) //watch out for this, be sure to correct msb
+	Z := curve.ScalarBaseMult(k)
+	zBytes, _ := Z.MarshalBinary()
 
-	temp := W.x.Bytes()
+	temp := W.AffineX().Bytes()
 	ke_ka := KMACXOF256(&temp, &[]byte{}, 1024, "P")
 	ke := ke_ka[:64]
 	ka := ke_ka[64:]
 
-	c := XorBytes(KMACXOF256(&ke, &[]byte{}, len(*message)*8, "PKE"), *message)
-	t := KMACXOF256(&ka, message, 512, "PKA")
-	cryptogram := ECCryptogram{Z_x: Z.x, Z_y: Z.y, C: c, T: t}
-	encoded, _ := encodeECCryptogram(&cryptogram)
-
-	return encoded
+	c := XorBytes(KMACXOF256(&ke, &[]byte{}, len(m)*8, "PKE"), m)
+	authData := authenticatedData(flags, m)
+	t := KMACXOF256(&ka, &authData, 512, "PKA")
+	cryptogram := ECCryptogram{Z: zBytes, C: c, T: t, F: flags, Curve: curve.ID()}
+	if !cfg.hideRecipient {
+		cryptogram.R = keyFingerprint(pubKey)
+	}
+	return encodeECCryptogram(&cryptogram)
 }
 
 /*
 Decrypts a cryptogram under password. Assumes cryptogram is well-formed.
-Operates under Schnorr/ECDHIES principle in that shared symmetric key is
-derived from Z.
 
-	s <- KMACXOF256(pw, “”, 512, “K”); s <- 4s
+	s <- KMACXOF256(pw, “”, 512, “K”); s <- 4s mod r, on the cryptogram's curve
+	pw: password used to generate the encryption key.
+	message: cryptogram of format Z||c||t
+	opts: optional settings such as withMaxDecompressedSize
+	return: Decryption of cryptogram Z||c||t iff t` = t, as raw bytes
+*/
+func decryptWithKey(pw []byte, message *ECCryptogram, opts ...cryptOption) ([]byte, error) {
+	curve, err := curveByID(message.Curve)
+	if err != nil {
+		return nil, err
+	}
+	return decryptWithPrivateKey(privateScalar(curve, pw), message, opts...)
+}
+
+/*
+Decrypts a cryptogram under a private scalar s, such as the PrivKey of a
+stored key. Operates under Schnorr/ECDHIES principle in that shared
+symmetric key is derived from Z.
+
 	W <- s*Z
 	(ke || ka) <- KMACXOF256(W x , “”, 1024, “P”)
 	m <- KMACXOF256(ke, “”, |c|, “PKE”) XOR c
 	t’ <- KMACXOF256(ka, m, 512, “PKA”)
-	pw: password used to generate E521 encryption key.
+	m <- decompress(unpad(m)) as recorded in the cryptogram flags
+	s: private scalar of the recipient key
 	message: cryptogram of format Z||c||t
-	return: Decryption of cryptogram Z||c||t iff t` = t
+	opts: optional settings such as withMaxDecompressedSize
+	return: Decryption of cryptogram Z||c||t iff t` = t, as raw bytes
 */
-func decryptWithKey(pw []byte, message *ECCryptogram) (*string, error) {
-
-	Z := NewE521XY(message.Z_x, message.Z_y)
+func decryptWithPrivateKey(s *Scalar, message *ECCryptogram, opts ...cryptOption) ([]byte, error) {
 
-	s := big.NewInt(0).SetBytes(KMACXOF256(&pw, &[]byte{}, 512, "K"))
-	s = s.Mul(s, big.NewInt(4))
-	s = s.Mod(s, &Z.n)
-
-	W := Z.SecMul(s)
-	fmt.Println(W.x.String())
-	fmt.Println(W.y.String())
+	if err := checkFlags(message.F); err != nil {
+		return nil, err
+	}
+	Z, err := message.nonce()
+	if err != nil {
+		return nil, err
+	}
+	if err := Z.validate(); err != nil {
+		return nil, err
+	}
+	W := Z.ScalarMult(s.BigInt())
 
-	temp := W.x.Bytes()
+	temp := W.AffineX().Bytes()
 	ke_ka := KMACXOF256(&temp, &[]byte{}, 1024, "P")
 	ke := ke_ka[:64]
 	ka := ke_ka[64:]
 	m := XorBytes(KMACXOF256(&ke, &[]byte{}, len(message.C)*8, "PKE"), message.C)
-	t_p := KMACXOF256(&ka, &m, 512, "PKA")
-	if bytes.Equal(t_p, message.T) {
-		result := string(m)
-		return &result, nil
-	} else {
-		return nil, errors.New("decryption failure")
+	authData := authenticatedData(message.F, m)
+	t_p := KMACXOF256(&ka, &authData, 512, "PKA")
+	if subtle.ConstantTimeCompare(t_p, message.T) != 1 {
+		zeroize(m)
+		zeroize(authData)
+		return nil, ErrAuthFailed
 	}
+	result, err := newCryptConfig(opts).decode(m, message.F)
+	if err != nil {
+		zeroize(m)
+		return nil, err
+	}
+	return result, nil
 }
 
 /*
 Generates a signature for a byte array m under passphrase pw:
 
-	s <- KMACXOF256(pw, “”, 512, “K”); s <- 4s
-	k <- KMACXOF256(s, m, 512, “N”); k <- 4k
+	s <- KMACXOF256(pw, “”, 512, “K”); s <- 4s mod r
+	k <- KMACXOF256(s, m, 512, “N”); k <- 4k mod r
 	U <- k*G;
-	h <- KMACXOF256(U x , m, 512, “T”); z <- (k – hs) mod r
+	h <- KMACXOF256(U x , m, 512, “T1”); z <- (k – hs) mod r
 
-	return: signature: (h, z)
+	return: committed signature: (h, z), carrying U so that it can be batch verified
 */
 func signWithKey(pw []byte, message *[]byte) (*[]byte, error) {
+	return signWithKeyOn(E521Curve, pw, message)
+}
+
+// Signs as signWithKey does, under the key derived from pw on the given curve.
+func signWithKeyOn(curve Curve, pw []byte, message *[]byte) (*[]byte, error) {
+	sig := signatureOn(curve, pw, *message)
+	sig.M = *message
+	result, err := encodeSignature(sig)
+
+	if err != nil {
+		return nil, wrapErr(ErrMalformed, err)
+	}
+	return result, nil
+}
+
+// Signs message under the key derived from pw on curve, naming the signer but not embedding message.
+func signatureOn(curve Curve, pw []byte, message []byte) *Signature {
+	s := privateScalar(curve, pw)
+	sig := schnorrSign(curve, s, message, sigVersionCommitted)
+	sig.F = keyFingerprint(curve.ScalarBaseMult(s.BigInt()))
+	return sig
+}
+
+/*
+Computes the Schnorr signature (h, z) of message under the private scalar s
+on curve in the given format version, without embedding the message:
 
-	s := new(big.Int).SetBytes(KMACXOF256(&pw, &[]byte{}, 512, "K"))
-	s = s.Mul(s, big.NewInt(4))
-	V := *E521GenPoint(0)
-	V = *V.SecMul(s)
+	k <- KMACXOF256(s, m, 512, “N”); k <- 4k mod r
+	U <- k*G
+	h <- sigChallenge(version, U, m); z <- (k – hs) mod r
+
+U is recorded only in committed signatures; the caller sets F.
+*/
+func schnorrSign(curve Curve, s *Scalar, message []byte, version byte) *Signature {
+	f := curve.scalars()
 	sBytes := s.Bytes()
 	//get signing key for messsage under password
-	k := new(big.Int).SetBytes(KMACXOF256(&sBytes, message, 512, "N"))
-	k = new(big.Int).Mul(k, big.NewInt(4))
+	k := f.zero().SetUniformBytes(KMACXOF256(&sBytes, &message, 512, "N"))
+	k.Mul(k, f.fromUint64(4))
+	zeroize(sBytes)
 	//create public signing key for message
-	U := E521GenPoint(0).SecMul(k)
-	uXBytes := U.x.Bytes()
+	U := curve.ScalarBaseMult(k.BigInt())
 	//get the tag for the message key
-	h := KMACXOF256(&uXBytes, message, 512, "T")
+	h := sigChallenge(version, U, message)
 	//create public nonce for signature
 	h_bigInt := new(big.Int).SetBytes(h)
-	z := new(big.Int).Sub(k, new(big.Int).Mul(h_bigInt, s))
-	z = new(big.Int).Mod(z, &E521IdPoint().r)
+	hs := f.zero().SetUniformBytes(h)
+	z := f.zero().Sub(k, hs.Mul(hs, s))
 	// z = (k - hs) mod r
-	sig := Signature{M: *message, H: h_bigInt, Z: z}
-	result, err := encodeSignature(&sig)
+	sig := &Signature{H: h_bigInt, Z: z.BigInt(), C: curve.ID(), V: version}
+	if version == sigVersionCommitted {
+		sig.U, _ = U.MarshalBinary()
+	}
+	return sig
+}
 
-	if err != nil {
-		return nil, errors.New("failed to encode signature")
-	} else {
-		return result, nil
+// Length in bits of the hash h in a signature.
+const sigHashBits = 512
+
+/*
+Computes the challenge h of a signature in the given format version:
+
+	legacy:    h <- KMACXOF256(U x , m, 512, “T”)
+	committed: h <- KMACXOF256(U x , m, 512, “T1”)
+	detached:  h <- KMACXOF256(U x , m, 512, “SOAP-DETACHED-SIG”)
+*/
+func sigChallenge(version byte, U Point, message []byte) []byte {
+	S := "T"
+	switch version {
+	case sigVersionCommitted:
+		S = "T1"
+	case sigVersionDetached:
+		S = "SOAP-DETACHED-SIG"
+	}
+	UXbytes := U.AffineX().Bytes()
+	return KMACXOF256(&UXbytes, &message, sigHashBits, S)
+}
+
+/*
+Checks that an encoded signature is in the one canonical form a signature
+on curve can take, as checkForm, and that its version may appear in an
+encoded Signature.
+
+	return: nil, an error wrapping ErrMalformed, or ErrUnsupportedVersion
+	        for an unknown format version
+*/
+func (sig *Signature) checkCanonical(curve Curve) error {
+	if sig.V == sigVersionDetached {
+		return wrapErr(ErrUnsupportedVersion, errors.New("unknown signature version"))
+	}
+	return sig.checkForm(curve)
+}
+
+/*
+Checks that sig is in the one canonical form a signature on curve can take:
+h and z are present, 0 <= h < 2^512 and 0 <= z < q for the order q of the
+curve's generator, and the recorded curve is curve. A legacy or detached
+signature records neither U nor a signer fingerprint; a committed one
+records both, the fingerprint at its full length. Any other values would
+give a second, equally valid encoding of the same signature.
+
+	return: nil, an error wrapping ErrMalformed, or ErrUnsupportedVersion
+	        for an unknown format version
+*/
+func (sig *Signature) checkForm(curve Curve) error {
+	switch {
+	case sig.H == nil || sig.Z == nil:
+		return wrapErr(ErrMalformed, errors.New("signature is missing h or z"))
+	case sig.H.Sign() < 0 || sig.H.BitLen() > sigHashBits:
+		return wrapErr(ErrMalformed, errors.New("signature hash h is out of range"))
+	case sig.Z.Sign() < 0 || sig.Z.Cmp(curve.Order()) >= 0:
+		return wrapErr(ErrMalformed, errors.New("signature scalar z is not reduced"))
+	case sig.C != curve.ID():
+		return wrapErr(ErrMalformed, errors.New("signature was made on another curve"))
+	case (sig.V == sigVersionLegacy || sig.V == sigVersionDetached) && (sig.U != nil || sig.F != nil):
+		return wrapErr(ErrMalformed, errors.New("legacy signature records U or a signer"))
+	case sig.V == sigVersionCommitted && len(sig.U) == 0:
+		return wrapErr(ErrMalformed, errors.New("signature is missing the commitment U"))
+	case sig.V == sigVersionCommitted && len(sig.F) != fingerprintSize:
+		return wrapErr(ErrMalformed, errors.New("invalid signer fingerprint"))
+	case sig.V > sigVersionDetached:
+		return wrapErr(ErrUnsupportedVersion, errors.New("unknown signature version"))
 	}
+	return nil
 }
 
 /*
@@ -330,19 +563,55 @@ Verifies a signature (h, z) for a byte array m under the (Schnorr/
 ECDHIES) public key V:
 
 	U <- z*G + h*V
-	sig: signature: (h, z)
-	pubKey: E521 key V used to sign message m
-	return: true if, and only if, KMACXOF256(U x , m, 512, “T”) = h
+	sig: signature: (h, z), canonical as checked by checkCanonical
+	pubKey: key V used to sign message m, on the curve recorded in sig;
+	        a valid point of the prime order subgroup other than the identity
+	return: true if, and only if, sig is a committed signature,
+	        sigChallenge(version, U, m) = h, U is not the identity, and the
+	        recorded U and signer fingerprint equal the computed U and the
+	        fingerprint of V
+
+Legacy signatures are rejected; see verifyMessage.
 */
-func verify(pubkey *E521, sig *Signature, message *[]byte) bool {
+func verify(pubkey Point, sig *Signature, message *[]byte) bool {
+	return verifyVersion(pubkey, sig, *message, sigVersionCommitted)
+}
+
+/*
+Verifies a signature on a SOAP message as verify does, but also accepts a
+legacy signature, made before U and the fingerprint were recorded. Only the
+verification of signed SOAP messages opts in to legacy signatures.
+*/
+func verifyMessage(pubkey Point, sig *Signature, message *[]byte) bool {
+	if sig.V == sigVersionLegacy {
+		return verifyVersion(pubkey, sig, *message, sigVersionLegacy)
+	}
+	return verify(pubkey, sig, message)
+}
 
-	U2 := E521GenPoint(0).SecMul(sig.Z).Add(pubkey.SecMul(sig.H))
-	UXbytes := U2.x.Bytes()
-	h_p := KMACXOF256(&UXbytes, message, 512, "T")
-	h2 := new(big.Int).SetBytes(h_p)
-	if h2.Cmp(sig.H) != 0 {
+// Verifies sig as verify does, provided it has the given version.
+func verifyVersion(pubkey Point, sig *Signature, message []byte, version byte) bool {
+	curve := pubkey.Curve()
+	if sig.V != version || sig.checkForm(curve) != nil || pubkey.validate() != nil {
 		return false
-	} else {
-		return true
 	}
+	if sig.V == sigVersionCommitted && !bytes.Equal(sig.F, keyFingerprint(pubkey)) {
+		return false
+	}
+	// z, h and V are all public
+	U2 := curve.MultiScalarMul([]Point{curve.Generator(), pubkey}, []*big.Int{sig.Z, sig.H})
+	if U2.IsIdentity() {
+		return false
+	}
+	if sig.V == sigVersionCommitted {
+		// the recorded commitment must be exactly the recomputed one
+		uBytes, _ := U2.MarshalBinary()
+		if subtle.ConstantTimeCompare(uBytes, sig.U) != 1 {
+			return false
+		}
+	}
+	h_p := sigChallenge(sig.V, U2, message)
+	h := make([]byte, len(h_p))
+	sig.H.FillBytes(h)
+	return subtle.ConstantTimeCompare(h_p, h) == 1
 }
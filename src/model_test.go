@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var updateVectors = flag.Bool("update", false, "regenerate testdata known-answer vectors")
+
+const vectorFile = "cryptograms.json"
+
+/*
+A cryptogram produced under fixed randomness. The cryptogram is recorded
+as its fields rather than as the gob stream the tool writes: gob numbers
+types in the order a process first encodes them, so the stream depends on
+the encoder's state and no other implementation could reproduce it. Byte
+strings are hex encoded and Z is in the compressed form of MarshalBinary.
+
+Vectors marked decrypt-only hold compressed plaintexts. Their cryptograms
+depend on the DEFLATE output of the encoder that recorded them, which
+other implementations and later Go releases need not reproduce, so only
+their decryption is checked.
+*/
+type testVector struct {
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`                  // "symmetric" or "ec"
+	Password    string `json:"password"`              // passphrase, or recipient key passphrase for "ec"
+	Message     string `json:"message"`               // hex encoded plaintext
+	Random      string `json:"random"`                // hex encoded randomness consumed by the encryption
+	Padding     byte   `json:"padding"`               // padding scheme passed to withPadding
+	Compress    bool   `json:"compress"`              // whether withCompression was requested
+	DecryptOnly bool   `json:"decryptOnly,omitempty"` // the cryptogram is not reproduced by encryption
+	Curve       byte   `json:"curve"`                 // curve ID of the recipient key, 0 for "symmetric"
+	Recipient   string `json:"recipient,omitempty"`   // R, fingerprint of the recipient key
+	Flags       byte   `json:"flags"`                 // F, transforms applied to the plaintext
+	Nonce       string `json:"nonce"`                 // z, or the public nonce Z for "ec"
+	Ciphertext  string `json:"ciphertext"`            // c
+	Tag         string `json:"tag"`                   // t
+}
+
+// Public key V derived from a key passphrase as in generateKeyPair.
+func testPublicKey(t testing.TB, pw string) Point {
+	t.Helper()
+	key := KeyObj{}
+	generateKeyPair(&key, pw, "test")
+	V, err := key.publicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return V
+}
+
+// Encrypts the vector's message, reading randomness from the vector.
+func (v *testVector) encrypt(t *testing.T) []byte {
+	t.Helper()
+	msg, _ := hex.DecodeString(v.Message)
+	rnd, _ := hex.DecodeString(v.Random)
+	opts := []cryptOption{withRand(bytes.NewReader(rnd)), withPadding(v.Padding), withCompression(v.Compress)}
+	var cg *[]byte
+	var err error
+	if v.Kind == "ec" {
+		cg, err = encryptWithKey(testPublicKey(t, v.Password), &msg, opts...)
+	} else {
+		cg, err = encryptWithPW([]byte(v.Password), &msg, opts...)
+	}
+	if err != nil {
+		t.Fatalf("%s: encryption failed: %v", v.Name, err)
+	}
+	return *cg
+}
+
+// Records the fields of an encoded cryptogram of the vector's kind.
+func (v *testVector) record(t *testing.T, raw []byte) {
+	t.Helper()
+	if v.Kind == "ec" {
+		cg, err := decodeECCryptogram(&raw)
+		if err != nil {
+			t.Fatalf("%s: %v", v.Name, err)
+		}
+		v.Curve, v.Recipient, v.Flags = cg.Curve, hex.EncodeToString(cg.R), cg.F
+		v.Nonce, v.Ciphertext, v.Tag = hex.EncodeToString(cg.Z), hex.EncodeToString(cg.C), hex.EncodeToString(cg.T)
+		return
+	}
+	cg, err := decodeSymCryptogram(&raw)
+	if err != nil {
+		t.Fatalf("%s: %v", v.Name, err)
+	}
+	v.Flags = cg.F
+	v.Nonce, v.Ciphertext, v.Tag = hex.EncodeToString(cg.Z), hex.EncodeToString(cg.C), hex.EncodeToString(cg.T)
+}
+
+// Decrypts the cryptogram recorded in the vector under its password.
+func (v *testVector) decrypt(t *testing.T) []byte {
+	t.Helper()
+	R, _ := hex.DecodeString(v.Recipient)
+	Z, _ := hex.DecodeString(v.Nonce)
+	c, _ := hex.DecodeString(v.Ciphertext)
+	tag, _ := hex.DecodeString(v.Tag)
+	if v.Kind == "ec" {
+		m, err := decryptWithKey([]byte(v.Password), &ECCryptogram{Z: Z, C: c, T: tag, F: v.Flags, R: R, Curve: v.Curve})
+		if err != nil {
+			t.Fatalf("%s: decryption failed: %v", v.Name, err)
+		}
+		return m
+	}
+	m, err := decryptWithPW([]byte(v.Password), &SymCryptogram{Z: Z, C: c, T: tag, F: v.Flags})
+	if err != nil {
+		t.Fatalf("%s: decryption failed: %v", v.Name, err)
+	}
+	return *m
+}
+
+// Inputs for the checked in vectors. Randomness is derived from the name
+// so that regenerating the file is itself reproducible.
+func vectorInputs() []testVector {
+	long := bytes.Repeat([]byte(`{"level":"info","msg":"status ok"}`+"\n"), 40)
+	inputs := []testVector{
+		{Name: "sym-empty", Kind: "symmetric", Password: "", Message: ""},
+		{Name: "sym-short", Kind: "symmetric", Password: "pw", Message: hex.EncodeToString([]byte("status: ok"))},
+		{Name: "sym-padme", Kind: "symmetric", Password: "pw", Message: hex.EncodeToString([]byte("status: ok")), Padding: flagPadme},
+		{Name: "sym-pow2", Kind: "symmetric", Password: "pw", Message: hex.EncodeToString([]byte("status: ok")), Padding: flagPow2},
+		{Name: "sym-deflate-padme", Kind: "symmetric", Password: "pw", Message: hex.EncodeToString(long), Padding: flagPadme, Compress: true, DecryptOnly: true},
+		{Name: "ec-short", Kind: "ec", Password: "recipient", Message: hex.EncodeToString([]byte("status: ok"))},
+		{Name: "ec-deflate-pow2", Kind: "ec", Password: "recipient", Message: hex.EncodeToString(long), Padding: flagPow2, Compress: true, DecryptOnly: true},
+	}
+	for i := range inputs {
+		name := []byte(inputs[i].Name)
+		inputs[i].Random = hex.EncodeToString(cSHAKE256(&name, 512, "", "TEST-VECTOR"))
+	}
+	return inputs
+}
+
+func TestKnownAnswerVectors(t *testing.T) {
+	path := filepath.Join("testdata", vectorFile)
+	if *updateVectors {
+		vectors := vectorInputs()
+		for i := range vectors {
+			vectors[i].record(t, vectors[i].encrypt(t))
+		}
+		data, _ := json.MarshalIndent(vectors, "", "  ")
+		if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []testVector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	for i := range vectors {
+		v := &vectors[i]
+		if v.Compress != v.DecryptOnly {
+			t.Errorf("%s: compressed vectors must be decrypt-only, and only they", v.Name)
+		}
+		if !v.DecryptOnly {
+			got := *v
+			got.record(t, v.encrypt(t))
+			if got != *v {
+				t.Errorf("%s: cryptogram mismatch under fixed randomness:\n got %+v\nwant %+v", v.Name, got, *v)
+			}
+		}
+		if got := hex.EncodeToString(v.decrypt(t)); got != v.Message {
+			t.Errorf("%s: decrypted message mismatch", v.Name)
+		}
+	}
+}
+
+func TestShortRandomnessIsAnError(t *testing.T) {
+	msg := []byte("message")
+	if _, err := encryptWithPW([]byte("pw"), &msg, withRand(bytes.NewReader(make([]byte, 10)))); err == nil {
+		t.Error("symmetric encryption accepted a short randomness source")
+	}
+	if _, err := encryptWithKey(testPublicKey(t, "pw"), &msg, withRand(bytes.NewReader(nil))); err == nil {
+		t.Error("EC encryption accepted a short randomness source")
+	}
+}
+
+func TestECBinaryRoundTrip(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), 0xff, 0x00, 0xfe)
+	cg, err := encryptWithKey(testPublicKey(t, "recipient"), &png)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := decodeECCryptogram(cg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := decryptWithKey([]byte("recipient"), decoded)
+	if err != nil || !bytes.Equal(m, png) {
+		t.Fatalf("binary plaintext did not round trip: %v", err)
+	}
+	if isDisplayableText(m) {
+		t.Error("binary plaintext classified as displayable text")
+	}
+	if !isDisplayableText([]byte("héllo, wörld 🙂\n\ttabbed\r\n")) {
+		t.Error("UTF-8 text classified as binary")
+	}
+}
+
+func TestInvalidPointsRejected(t *testing.T) {
+	V := testPublicKey(t, "recipient")
+	msg := []byte("message")
+	raw, err := encryptWithKey(V, &msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cg, _ := decodeECCryptogram(raw)
+
+	// (0, -1) has order 2 and lies outside the prime order subgroup
+	P := new(E521).getP()
+	lowOrder := NewE521XY(*big.NewInt(0), *new(big.Int).Sub(&P, big.NewInt(1)))
+	offCurve := NewE521XY(*big.NewInt(4), *big.NewInt(4))
+	for name, Z := range map[string]*E521{"identity": E521IdPoint(), "low order": lowOrder, "off curve": offCurve} {
+		bad := *cg
+		x, y := Z.affine()
+		bad.Z, bad.Z_x, bad.Z_y = nil, *x, *y
+		if _, err := decryptWithKey([]byte("recipient"), &bad); !errors.Is(err, ErrInvalidPoint) {
+			t.Errorf("%s nonce: got %v, want ErrInvalidPoint", name, err)
+		}
+		if _, err := encryptWithKey(Z, &msg); !errors.Is(err, ErrInvalidPoint) {
+			t.Errorf("%s public key: got %v, want ErrInvalidPoint", name, err)
+		}
+	}
+	if err := V.validate(); err != nil {
+		t.Errorf("valid public key rejected: %v", err)
+	}
+}
+
+func TestLegacyNonceCoordinates(t *testing.T) {
+	msg := []byte("message")
+	raw, err := encryptWithKey(testPublicKey(t, "recipient"), &msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cg, _ := decodeECCryptogram(raw)
+	Z, _ := cg.nonce()
+	x, y := Z.(*E521).affine()
+	cg.Z, cg.Z_x, cg.Z_y = nil, *x, *y
+	m, err := decryptWithKey([]byte("recipient"), cg)
+	if err != nil || !bytes.Equal(m, msg) {
+		t.Fatalf("legacy cryptogram did not decrypt: %v", err)
+	}
+	P := new(E521).getP()
+	cg.Z_x.Add(&cg.Z_x, &P)
+	if _, err := decryptWithKey([]byte("recipient"), cg); !errors.Is(err, ErrInvalidPoint) {
+		t.Errorf("unreduced legacy nonce: got %v, want ErrInvalidPoint", err)
+	}
+}
+
+// A signature and the key it is checked against.
+type malleation struct {
+	sig *Signature
+	key Point
+}
+
+// Variants of a valid signature by V, each of which must be rejected.
+func malleatedSignatures(sig *Signature, V Point) map[string]malleation {
+	r := V.Curve().Order()
+	two512 := new(big.Int).Lsh(big.NewInt(1), sigHashBits)
+	with := func(edit func(s *Signature)) *Signature {
+		c := *sig
+		edit(&c)
+		return &c
+	}
+	lowOrder := NewE521XY(*big.NewInt(0), *new(big.Int).Sub(&e521P, big.NewInt(1)))
+	identity, _ := E521IdPoint().MarshalBinary()
+	otherU, _ := E521GenPoint(0).MarshalBinary()
+	return map[string]malleation{
+		"z + r":          {with(func(s *Signature) { s.Z = new(big.Int).Add(s.Z, r) }), V},
+		"z - r":          {with(func(s *Signature) { s.Z = new(big.Int).Sub(s.Z, r) }), V},
+		"z + 2^600 r":    {with(func(s *Signature) { s.Z = new(big.Int).Add(s.Z, new(big.Int).Lsh(r, 600)) }), V},
+		"r - z":          {with(func(s *Signature) { s.Z = new(big.Int).Sub(r, s.Z) }), V},
+		"h + 2^512":      {with(func(s *Signature) { s.H = new(big.Int).Add(s.H, two512) }), V},
+		"h + r":          {with(func(s *Signature) { s.H = new(big.Int).Add(s.H, r) }), V},
+		"h - 2^512":      {with(func(s *Signature) { s.H = new(big.Int).Sub(s.H, two512) }), V},
+		"missing z":      {with(func(s *Signature) { s.Z = nil }), V},
+		"missing h":      {with(func(s *Signature) { s.H = nil }), V},
+		"other curve":    {with(func(s *Signature) { s.C = CurveEd448 }), V},
+		"other U":        {with(func(s *Signature) { s.U = otherU }), V},
+		"identity U":     {with(func(s *Signature) { s.U = identity }), V},
+		"truncated U":    {with(func(s *Signature) { s.U = s.U[1:] }), V},
+		"other signer":   {with(func(s *Signature) { s.F = keyFingerprint(E521GenPoint(0)) }), V},
+		"truncated F":    {with(func(s *Signature) { s.F = s.F[1:] }), V},
+		"stripped U":     {with(func(s *Signature) { s.U = nil }), V},
+		"stripped F":     {with(func(s *Signature) { s.F = nil }), V},
+		"stripped U, F":  {with(func(s *Signature) { s.U, s.F = nil, nil }), V},
+		"as legacy":      {with(func(s *Signature) { s.U, s.F, s.V = nil, nil, sigVersionLegacy }), V},
+		"legacy with U":  {with(func(s *Signature) { s.V = sigVersionLegacy }), V},
+		"version 2":      {with(func(s *Signature) { s.V = sigVersionCommitted + 1 }), V},
+		"identity key":   {sig, E521IdPoint()},
+		"low order key":  {sig, lowOrder},
+		"off curve key":  {sig, NewE521XY(*big.NewInt(4), *big.NewInt(4))},
+		"negated key":    {sig, V.(*E521).getOpposite()},
+		"key plus order": {sig, V.AddPoint(lowOrder)},
+	}
+}
+
+func TestMalleatedSignaturesRejected(t *testing.T) {
+	msg := []byte("pay 10 to bob")
+	V := testPublicKey(t, "alice")
+	raw, err := signWithKey([]byte("alice"), &msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := decodeSignature(raw)
+	if err != nil || !verify(V, sig, &msg) {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	for name, m := range malleatedSignatures(sig, V) {
+		if verify(m.key, m.sig, &msg) {
+			t.Errorf("%s: verify accepted the signature", name)
+		}
+		if verifyMessage(m.key, m.sig, &msg) {
+			t.Errorf("%s: verifyMessage accepted the signature", name)
+		}
+		if bad := VerifyBatch([]SignedItem{{PubKey: m.key, Message: msg, Sig: m.sig}}); len(bad) != 1 {
+			t.Errorf("%s: VerifyBatch accepted the signature", name)
+		}
+		batch := testSignedItems(t, 2)
+		batch = append(batch, SignedItem{PubKey: m.key, Message: msg, Sig: m.sig})
+		if bad := VerifyBatch(batch); !reflect.DeepEqual(bad, []int{2}) {
+			t.Errorf("%s: VerifyBatch reported %v in a batch of three", name, bad)
+		}
+	}
+	for _, name := range []string{"z + r", "z - r", "h + 2^512", "h - 2^512", "missing z", "truncated F",
+		"stripped U", "stripped F", "stripped U, F", "legacy with U"} {
+		enc, err := encodeSignature(malleatedSignatures(sig, V)[name].sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := decodeSignature(enc); !errors.Is(err, ErrMalformed) {
+			t.Errorf("%s: decodeSignature returned %v, want ErrMalformed", name, err)
+		}
+	}
+	enc, _ := encodeSignature(malleatedSignatures(sig, V)["version 2"].sig)
+	if _, err := decodeSignature(enc); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("unknown version: decodeSignature returned %v, want ErrUnsupportedVersion", err)
+	}
+}
+
+func TestSignerLookup(t *testing.T) {
+	msg := []byte("signed by alice")
+	alice := testKeyObj(t, E521Curve, "alice", "alice")
+	bob := testKeyObj(t, Ed448Curve, "bob", "bob")
+	raw, err := signWithKey([]byte("alice"), &msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := decodeSignature(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sig.F, alice.fingerprint()) {
+		t.Fatalf("signature names signer %x, want %x", sig.F, alice.fingerprint())
+	}
+	kt := &KeyTable{keyList: map[string]KeyObj{"alice": *alice, "bob": *bob}}
+	if key, err := kt.signerKey(sig.F, bob); err != nil || key.Id != "alice" {
+		t.Fatalf("signerKey: %v, %v", key, err)
+	}
+	if _, err := (&KeyTable{keyList: map[string]KeyObj{"bob": *bob}}).signerKey(sig.F, bob); !errors.Is(err, errUnknownSigner) ||
+		!strings.Contains(err.Error(), hex.EncodeToString(sig.F)) {
+		t.Errorf("unknown signer gave %v", err)
+	}
+
+	// signatures made before fingerprints were recorded use the selected key
+	legacy := schnorrSign(E521Curve, privateScalar(E521Curve, []byte("alice")), msg, sigVersionLegacy)
+	if key, err := kt.signerKey(legacy.F, alice); err != nil || key != alice {
+		t.Errorf("legacy signature: %v, %v", key, err)
+	}
+	if _, err := kt.signerKey(legacy.F, nil); !errors.Is(err, errUnknownSigner) {
+		t.Errorf("legacy signature without a selected key gave %v", err)
+	}
+	V, _ := alice.publicKey()
+	if verify(V, legacy, &msg) {
+		t.Error("verify accepted a legacy signature")
+	}
+	if !verifyMessage(V, legacy, &msg) {
+		t.Error("legacy signature without a fingerprint rejected")
+	}
+}
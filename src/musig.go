@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"sync/atomic"
+)
+
+/*
+MuSig2 multi-signatures (Nick, Ruffing and Seurin, CRYPTO 2021): n signers
+produce a single Schnorr signature that verify accepts under their
+aggregated public key, in two rounds.
+
+	keys      L = the keys sorted by encoding
+	          a_i <- KMACXOF256(cSHAKE256(L, 512, “”, “MUSIG-KEYS”), X_i, 512, “MUSIG-AGG”)
+	          X <- Σ a_i X_i
+	round 1   each signer publishes R_i1 = k_i1 G and R_i2 = k_i2 G
+	          R_1 <- Σ R_i1; R_2 <- Σ R_i2
+	          b <- KMACXOF256(X, R_1 || R_2 || m, 512, “MUSIG-NONCE”)
+	          U <- R_1 + b R_2
+	round 2   h <- KMACXOF256(U x , m, 512, “T1”), as in signWithKey
+	          z_i <- (k_i1 + b k_i2 – h a_i s_i) mod r
+	          z <- Σ z_i mod r
+
+so that zG + hX = U, the Schnorr verification equation. The coefficients
+a_i defeat rogue key attacks, and the second nonce makes the two rounds
+safe when many sessions run concurrently. The round messages are SOAP
+armored, so that signers can exchange them as files.
+
+	https://eprint.iacr.org/2020/1261
+*/
+
+// returned when a session step needs round messages that have not arrived yet
+var errMuSigIncomplete = errors.New("not every signer has taken part in this round")
+
+// returned when a session is asked to sign a second time with the same nonces
+var errMuSigNonceUsed = errors.New("nonces of this session have already been used")
+
+// The aggregate of the public keys of a set of co-signers.
+type MuSigKeyAgg struct {
+	curve  Curve
+	keys   []Point   // signer keys sorted by encoding
+	fps    [][]byte  // fingerprints of keys
+	coeffs []*Scalar // a_i
+	key    Point     // X
+}
+
+/*
+Aggregates the public keys of co-signers into the key X that their joint
+signatures verify under. The result does not depend on the order of keys.
+The keys must be valid, distinct and on the same curve; otherwise an error
+wrapping ErrMalformed or ErrInvalidPoint is returned.
+*/
+func AggregateKeys(keys []Point) (*MuSigKeyAgg, error) {
+	if len(keys) == 0 {
+		return nil, wrapErr(ErrMalformed, errors.New("no keys to aggregate"))
+	}
+	curve := keys[0].Curve()
+	encs := make([][]byte, len(keys))
+	sorted := append([]Point{}, keys...)
+	for i, P := range sorted {
+		if P.Curve() != curve {
+			return nil, wrapErr(ErrMalformed, errors.New("keys on different curves"))
+		}
+		if err := P.validate(); err != nil {
+			return nil, err
+		}
+		encs[i], _ = P.MarshalBinary()
+	}
+	sort.Sort(pointsByEncoding{sorted, encs})
+	var list []byte
+	for i := range encs {
+		if i > 0 && bytes.Equal(encs[i], encs[i-1]) {
+			return nil, wrapErr(ErrMalformed, errors.New("duplicate key"))
+		}
+		list = append(list, encodeString(encs[i])...)
+	}
+	keysHash := cSHAKE256(&list, 512, "", "MUSIG-KEYS")
+
+	f := curve.scalars()
+	agg := &MuSigKeyAgg{curve: curve, keys: sorted}
+	coeffs := make([]*big.Int, len(sorted))
+	for i, P := range sorted {
+		a := f.zero().SetUniformBytes(KMACXOF256(&keysHash, &encs[i], 512, "MUSIG-AGG"))
+		agg.fps = append(agg.fps, keyFingerprint(P))
+		agg.coeffs = append(agg.coeffs, a)
+		coeffs[i] = a.BigInt()
+	}
+	agg.key = curve.MultiScalarMul(sorted, coeffs)
+	if err := agg.key.validate(); err != nil {
+		return nil, err
+	}
+	return agg, nil
+}
+
+// Sorts points together with their encodings.
+type pointsByEncoding struct {
+	points []Point
+	encs   [][]byte
+}
+
+func (p pointsByEncoding) Len() int           { return len(p.points) }
+func (p pointsByEncoding) Less(i, j int) bool { return bytes.Compare(p.encs[i], p.encs[j]) < 0 }
+func (p pointsByEncoding) Swap(i, j int) {
+	p.points[i], p.points[j] = p.points[j], p.points[i]
+	p.encs[i], p.encs[j] = p.encs[j], p.encs[i]
+}
+
+// The aggregated public key X.
+func (agg *MuSigKeyAgg) PublicKey() Point { return agg.key }
+
+// Position of the key with fingerprint fp, or -1 if it is not aggregated.
+func (agg *MuSigKeyAgg) index(fp []byte) int {
+	for i := range agg.fps {
+		if bytes.Equal(agg.fps[i], fp) {
+			return i
+		}
+	}
+	return -1
+}
+
+// First round message: a signer's public nonces.
+type MuSigNonce struct {
+	Signer []byte // fingerprint of the signer's public key
+	R1, R2 []byte // encodings of R_i1 and R_i2
+}
+
+// Second round message: a signer's partial signature.
+type MuSigPartial struct {
+	Signer []byte   // fingerprint of the signer's public key
+	Z      *big.Int // z_i
+}
+
+/*
+The state of one signer in one signing session. A session signs a single
+message, and its secret nonces are erased once its partial signature has
+been produced, so a session can never sign twice.
+*/
+type MuSigSession struct {
+	agg     *MuSigKeyAgg
+	signer  *Signer
+	index   int // position of the signer's key in agg
+	message []byte
+	k1, k2  *Scalar   // secret nonces, nil once used
+	r1, r2  []Point   // public nonces by key position
+	z       []*Scalar // verified partial signatures by key position
+	// derived once every public nonce is known
+	b *Scalar
+	U Point
+	h []byte
+}
+
+// Number of sessions started by this process, mixed into every nonce derivation.
+var muSigSessions atomic.Uint64
+
+/*
+Starts a session in which signer, one of the keys of agg, signs message.
+Returns the session and the first round message to send to the other
+signers.
+
+rng must be a cryptographically secure generator. The nonces are derived
+from 64 bytes of rng together with the private key, the aggregated key,
+the message and a per-process session counter. The counter gives fresh
+nonces if rng repeats a seed within one process, but nothing makes a weak
+rng safe: once the nonces of two sessions on the same message repeat, a
+co-signer who changes their own nonces, and so b, between the sessions can
+solve the partial signatures z = k1 + b k2 - h a s for the private key.
+*/
+func NewMuSigSession(agg *MuSigKeyAgg, signer *Signer, message []byte, rng io.Reader) (*MuSigSession, *MuSigNonce, error) {
+	index := agg.index(signer.fp)
+	if signer.curve != agg.curve || index < 0 {
+		return nil, nil, wrapErr(ErrMalformed, errors.New("signer's key is not one of the aggregated keys"))
+	}
+	seed, err := readRandomBytes(rng, 64)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zeroize(seed)
+	X, _ := agg.key.MarshalBinary()
+	counter := binary.BigEndian.AppendUint64(nil, muSigSessions.Add(1))
+	data := append(signer.s.Bytes(), encodeString(X)...)
+	data = append(data, encodeString(counter)...)
+	data = append(data, message...)
+	defer zeroize(data)
+
+	f := agg.curve.scalars()
+	n := len(agg.keys)
+	ss := &MuSigSession{
+		agg:     agg,
+		signer:  signer,
+		index:   index,
+		message: append([]byte{}, message...),
+		k1:      f.zero().SetUniformBytes(KMACXOF256(&seed, &data, 512, "MUSIG-K1")),
+		k2:      f.zero().SetUniformBytes(KMACXOF256(&seed, &data, 512, "MUSIG-K2")),
+		r1:      make([]Point, n),
+		r2:      make([]Point, n),
+		z:       make([]*Scalar, n),
+	}
+	nonce := &MuSigNonce{Signer: signer.fp}
+	nonce.R1, _ = agg.curve.ScalarBaseMult(ss.k1.BigInt()).MarshalBinary()
+	nonce.R2, _ = agg.curve.ScalarBaseMult(ss.k2.BigInt()).MarshalBinary()
+	if err := ss.AddNonce(nonce); err != nil {
+		return nil, nil, err
+	}
+	return ss, nonce, nil
+}
+
+/*
+Records another signer's first round message. Receiving the same message
+twice is harmless; a different nonce from a signer that already sent one
+is an error wrapping ErrMalformed.
+*/
+func (ss *MuSigSession) AddNonce(n *MuSigNonce) error {
+	i := ss.agg.index(n.Signer)
+	if i < 0 {
+		return wrapErr(ErrMalformed, fmt.Errorf("nonce from %x, who is not a signer of this session", n.Signer))
+	}
+	R1, err := ss.decodeNonce(n.R1)
+	if err != nil {
+		return err
+	}
+	R2, err := ss.decodeNonce(n.R2)
+	if err != nil {
+		return err
+	}
+	if ss.r1[i] != nil {
+		if ss.r1[i].Equal(R1) && ss.r2[i].Equal(R2) {
+			return nil
+		}
+		return wrapErr(ErrMalformed, fmt.Errorf("conflicting nonces from %x", n.Signer))
+	}
+	ss.r1[i], ss.r2[i] = R1, R2
+	for _, R := range ss.r1 {
+		if R == nil {
+			return nil
+		}
+	}
+	return ss.aggregateNonces()
+}
+
+// Decodes a public nonce, rejecting invalid points.
+func (ss *MuSigSession) decodeNonce(data []byte) (Point, error) {
+	R, err := ss.agg.curve.DecodePoint(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := R.validate(); err != nil {
+		return nil, err
+	}
+	return R, nil
+}
+
+// Computes b, the joint nonce U and the challenge h once every nonce is known.
+func (ss *MuSigSession) aggregateNonces() error {
+	R1, R2 := ss.r1[0], ss.r2[0]
+	for i := 1; i < len(ss.r1); i++ {
+		R1, R2 = R1.AddPoint(ss.r1[i]), R2.AddPoint(ss.r2[i])
+	}
+	X, _ := ss.agg.key.MarshalBinary()
+	enc1, _ := R1.MarshalBinary()
+	enc2, _ := R2.MarshalBinary()
+	data := append(encodeString(enc1), encodeString(enc2)...)
+	data = append(data, ss.message...)
+	b := ss.agg.curve.scalars().zero().SetUniformBytes(KMACXOF256(&X, &data, 512, "MUSIG-NONCE"))
+	U := ss.agg.curve.MultiScalarMul([]Point{R1, R2}, []*big.Int{big.NewInt(1), b.BigInt()})
+	if U.IsIdentity() {
+		return wrapErr(ErrInvalidPoint, errors.New("joint nonce is the identity"))
+	}
+	ss.b, ss.U, ss.h = b, U, sigChallenge(sigVersionCommitted, U, ss.message)
+	return nil
+}
+
+/*
+Produces this signer's second round message once every first round message
+has been added, and erases the secret nonces.
+
+	return: the partial signature, errMuSigIncomplete if nonces are missing,
+	or errMuSigNonceUsed if the session has already signed
+*/
+func (ss *MuSigSession) Sign() (*MuSigPartial, error) {
+	if ss.U == nil {
+		return nil, errMuSigIncomplete
+	}
+	if ss.k1 == nil {
+		return nil, errMuSigNonceUsed
+	}
+	f := ss.agg.curve.scalars()
+	// z_i = k_i1 + b k_i2 – h a_i s_i
+	has := f.zero().SetUniformBytes(ss.h)
+	has.Mul(has, ss.agg.coeffs[ss.index])
+	has.Mul(has, ss.signer.s)
+	z := f.zero().Mul(ss.b, ss.k2)
+	z.Add(z, ss.k1)
+	z.Sub(z, has)
+	*ss.k1, *ss.k2 = Scalar{}, Scalar{}
+	ss.k1, ss.k2 = nil, nil
+	ss.z[ss.index] = z
+	return &MuSigPartial{Signer: ss.signer.fp, Z: z.BigInt()}, nil
+}
+
+/*
+Records and checks another signer's partial signature:
+
+	z_i G + h a_i X_i = R_i1 + b R_i2
+
+	return: nil, errMuSigIncomplete if nonces are missing, or an error
+	wrapping ErrMalformed for an unknown signer or out of range z_i and
+	ErrAuthFailed for an invalid partial signature
+*/
+func (ss *MuSigSession) AddPartial(p *MuSigPartial) error {
+	if ss.U == nil {
+		return errMuSigIncomplete
+	}
+	i := ss.agg.index(p.Signer)
+	if i < 0 {
+		return wrapErr(ErrMalformed, fmt.Errorf("partial signature from %x, who is not a signer of this session", p.Signer))
+	}
+	curve := ss.agg.curve
+	if p.Z == nil || p.Z.Sign() < 0 || p.Z.Cmp(curve.Order()) >= 0 {
+		return wrapErr(ErrMalformed, errors.New("partial signature out of range"))
+	}
+	z := curve.scalars().zero().SetBigInt(p.Z)
+	ha := curve.scalars().zero().SetUniformBytes(ss.h)
+	ha.Mul(ha, ss.agg.coeffs[i])
+	// the partial signature, nonces, keys and coefficients are all public
+	lhs := curve.MultiScalarMul([]Point{curve.Generator(), ss.agg.keys[i]}, []*big.Int{p.Z, ha.BigInt()})
+	rhs := curve.MultiScalarMul([]Point{ss.r1[i], ss.r2[i]}, []*big.Int{big.NewInt(1), ss.b.BigInt()})
+	if !lhs.Equal(rhs) {
+		return wrapErr(ErrAuthFailed, fmt.Errorf("invalid partial signature from %x", p.Signer))
+	}
+	ss.z[i] = z
+	return nil
+}
+
+/*
+Combines the partial signatures of every signer into a signature that
+verify accepts under the aggregated key. The signature records U and the
+fingerprint of the aggregated key but not the message.
+
+	return: the signature, or errMuSigIncomplete if partial signatures are missing
+*/
+func (ss *MuSigSession) Signature() (*Signature, error) {
+	if ss.U == nil {
+		return nil, errMuSigIncomplete
+	}
+	z := ss.agg.curve.scalars().zero()
+	for _, zi := range ss.z {
+		if zi == nil {
+			return nil, errMuSigIncomplete
+		}
+		z.Add(z, zi)
+	}
+	U, _ := ss.U.MarshalBinary()
+	sig := &Signature{
+		H: new(big.Int).SetBytes(ss.h),
+		Z: z.BigInt(),
+		U: U,
+		C: ss.agg.curve.ID(),
+		F: keyFingerprint(ss.agg.key),
+		V: sigVersionCommitted,
+	}
+	if !verify(ss.agg.key, sig, &ss.message) {
+		return nil, ErrAuthFailed
+	}
+	return sig, nil
+}
+
+// Encodes a round message in SOAP armor between begin and end.
+func encodeMuSigMessage(msg interface{}, begin, end string) (string, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(msg); err != nil {
+		return "", errors.New("failed to encode round message")
+	}
+	return formatSOAP(buf.Bytes(), begin, end), nil
+}
+
+// Decodes a round message armored between begin and end into msg.
+func decodeMuSigMessage(text, begin, end string, msg interface{}) error {
+	data, err := parseSOAP(&text, begin, end)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(*data)).Decode(msg); err != nil {
+		return wrapErr(ErrMalformed, err)
+	}
+	return nil
+}
+
+// Encodes a first round message for exchange between signers.
+func encodeMuSigNonce(n *MuSigNonce) (string, error) {
+	return encodeMuSigMessage(n, muSigNonceBegin, muSigNonceEnd)
+}
+
+// Parses an armored first round message. Its points are checked by AddNonce.
+func decodeMuSigNonce(text string) (*MuSigNonce, error) {
+	var n MuSigNonce
+	if err := decodeMuSigMessage(text, muSigNonceBegin, muSigNonceEnd, &n); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// Encodes a second round message for exchange between signers.
+func encodeMuSigPartial(p *MuSigPartial) (string, error) {
+	return encodeMuSigMessage(p, muSigPartialBegin, muSigPartialEnd)
+}
+
+// Parses an armored second round message. Its value is checked by AddPartial.
+func decodeMuSigPartial(text string) (*MuSigPartial, error) {
+	var p MuSigPartial
+	if err := decodeMuSigMessage(text, muSigPartialBegin, muSigPartialEnd, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
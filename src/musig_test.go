@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// Signers and their public keys for a co-signing test.
+func testCoSigners(t *testing.T, curve Curve, names ...string) ([]*Signer, []Point) {
+	t.Helper()
+	var signers []*Signer
+	var keys []Point
+	for _, name := range names {
+		key := testKeyObj(t, curve, name, name)
+		signer, err := NewSigner(key, []byte(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		V, _ := key.publicKey()
+		signers = append(signers, signer)
+		keys = append(keys, V)
+	}
+	return signers, keys
+}
+
+// Runs both rounds between sessions, passing every message through its armor.
+func runMuSig(t *testing.T, agg *MuSigKeyAgg, signers []*Signer, msg []byte) []*MuSigSession {
+	t.Helper()
+	sessions := make([]*MuSigSession, len(signers))
+	var nonces []string
+	for i, signer := range signers {
+		ss, nonce, err := NewMuSigSession(agg, signer, msg, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, err := encodeMuSigNonce(nonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions[i], nonces = ss, append(nonces, text)
+	}
+	var partials []string
+	for _, ss := range sessions {
+		for _, text := range nonces {
+			nonce, err := decodeMuSigNonce(text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ss.AddNonce(nonce); err != nil {
+				t.Fatal(err)
+			}
+		}
+		partial, err := ss.Sign()
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, _ := encodeMuSigPartial(partial)
+		partials = append(partials, text)
+	}
+	for _, ss := range sessions {
+		for _, text := range partials {
+			partial, err := decodeMuSigPartial(text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ss.AddPartial(partial); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return sessions
+}
+
+func TestMuSig(t *testing.T) {
+	msg := []byte("release v2.0.0 sha3 0123456789abcdef")
+	for _, c := range []struct {
+		curve Curve
+		names []string
+	}{
+		{E521Curve, []string{"alice", "bob", "carol"}},
+		{E521Curve, []string{"solo"}},
+		{Ed448Curve, []string{"alice", "bob"}},
+	} {
+		signers, keys := testCoSigners(t, c.curve, c.names...)
+		agg, err := AggregateKeys(keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reversedKeys := append([]Point{}, keys...)
+		for i, j := 0, len(reversedKeys)-1; i < j; i, j = i+1, j-1 {
+			reversedKeys[i], reversedKeys[j] = reversedKeys[j], reversedKeys[i]
+		}
+		if again, _ := AggregateKeys(reversedKeys); !again.PublicKey().Equal(agg.PublicKey()) {
+			t.Fatalf("%s: aggregated key depends on the order of the keys", c.curve.Name())
+		}
+
+		sessions := runMuSig(t, agg, signers, msg)
+		var first []byte
+		for _, ss := range sessions {
+			sig, err := ss.Signature()
+			if err != nil {
+				t.Fatalf("%s %v: %v", c.curve.Name(), c.names, err)
+			}
+			raw, err := encodeSignature(sig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := decodeSignature(raw)
+			if err != nil || !verify(agg.PublicKey(), decoded, &msg) {
+				t.Fatalf("%s %v: joint signature rejected: %v", c.curve.Name(), c.names, err)
+			}
+			if first == nil {
+				first = sig.Z.Bytes()
+			} else if !bytes.Equal(first, sig.Z.Bytes()) {
+				t.Errorf("%s: sessions combined different signatures", c.curve.Name())
+			}
+			if bad := VerifyBatch([]SignedItem{{PubKey: agg.PublicKey(), Message: msg, Sig: sig}}); len(bad) != 0 {
+				t.Errorf("%s: VerifyBatch rejected the joint signature", c.curve.Name())
+			}
+			other := []byte("release v2.0.1")
+			if verify(agg.PublicKey(), sig, &other) || (len(keys) > 1 && verify(keys[0], sig, &msg)) {
+				t.Errorf("%s: joint signature verifies beyond its key and message", c.curve.Name())
+			}
+
+			// the key table finds the aggregated key by the signature's fingerprint
+			pub, _ := agg.PublicKey().MarshalBinary()
+			release := KeyObj{Id: "release", Owner: "maintainers", KeyType: "PUBLIC", PubKey: hex.EncodeToString(pub)}
+			if c.curve.ID() != CurveE521 {
+				release.Curve = c.curve.Name()
+			}
+			kt := &KeyTable{keyList: map[string]KeyObj{"release": release}}
+			if key, err := kt.signerKey(sig.F, nil); err != nil || key.Owner != "maintainers" {
+				t.Errorf("%s: aggregated key not found: %v", c.curve.Name(), err)
+			}
+		}
+	}
+}
+
+func TestMuSigRejections(t *testing.T) {
+	msg := []byte("release")
+	signers, keys := testCoSigners(t, E521Curve, "alice", "bob")
+	agg, _ := AggregateKeys(keys)
+	outsiders, outsiderKeys := testCoSigners(t, E521Curve, "mallory")
+
+	if _, err := AggregateKeys(append(keys, keys[0])); !errors.Is(err, ErrMalformed) {
+		t.Errorf("duplicate key: got %v, want ErrMalformed", err)
+	}
+	if _, err := AggregateKeys(append(keys, E521IdPoint())); !errors.Is(err, ErrInvalidPoint) {
+		t.Errorf("identity key: got %v, want ErrInvalidPoint", err)
+	}
+	if _, _, err := NewMuSigSession(agg, outsiders[0], msg, rand.Reader); !errors.Is(err, ErrMalformed) {
+		t.Errorf("outside signer: got %v, want ErrMalformed", err)
+	}
+
+	seed := make([]byte, 64)
+	_, first, _ := NewMuSigSession(agg, signers[0], msg, bytes.NewReader(seed))
+	_, again, _ := NewMuSigSession(agg, signers[0], msg, bytes.NewReader(seed))
+	if bytes.Equal(first.R1, again.R1) || bytes.Equal(first.R2, again.R2) {
+		t.Error("a repeated rng seed repeated the nonces")
+	}
+
+	alice, aliceNonce, _ := NewMuSigSession(agg, signers[0], msg, rand.Reader)
+	if _, err := alice.Sign(); err != errMuSigIncomplete {
+		t.Errorf("signing before every nonce is known: got %v", err)
+	}
+	bob, bobNonce, _ := NewMuSigSession(agg, signers[1], msg, rand.Reader)
+	_, malloryNonce, _ := NewMuSigSession(func() *MuSigKeyAgg { a, _ := AggregateKeys(outsiderKeys); return a }(), outsiders[0], msg, rand.Reader)
+	if err := alice.AddNonce(malloryNonce); !errors.Is(err, ErrMalformed) {
+		t.Errorf("nonce from an outsider: got %v, want ErrMalformed", err)
+	}
+	if err := alice.AddNonce(&MuSigNonce{Signer: bobNonce.Signer, R1: bobNonce.R1, R2: bobNonce.R1[1:]}); !errors.Is(err, ErrMalformed) {
+		t.Errorf("truncated nonce: got %v, want ErrMalformed", err)
+	}
+	alice.AddNonce(bobNonce)
+	bob.AddNonce(aliceNonce)
+	if err := alice.AddNonce(&MuSigNonce{Signer: bobNonce.Signer, R1: bobNonce.R2, R2: bobNonce.R1}); !errors.Is(err, ErrMalformed) {
+		t.Errorf("conflicting nonce: got %v, want ErrMalformed", err)
+	}
+	if _, err := alice.Signature(); err != errMuSigIncomplete {
+		t.Errorf("combining without partial signatures: got %v", err)
+	}
+	if _, err := alice.Sign(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := alice.Sign(); err != errMuSigNonceUsed {
+		t.Errorf("signing twice: got %v, want errMuSigNonceUsed", err)
+	}
+	partial, _ := bob.Sign()
+	doubled := new(big.Int).Lsh(partial.Z, 1)
+	forged := &MuSigPartial{Signer: partial.Signer, Z: doubled.Mod(doubled, E521Curve.Order())}
+	if err := alice.AddPartial(forged); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("invalid partial signature: got %v, want ErrAuthFailed", err)
+	}
+	if err := alice.AddPartial(&MuSigPartial{Signer: partial.Signer, Z: E521Curve.Order()}); !errors.Is(err, ErrMalformed) {
+		t.Errorf("partial signature of r: got %v, want ErrMalformed", err)
+	}
+	if _, err := decodeMuSigPartial(formatSOAP([]byte("junk"), muSigPartialBegin, muSigPartialEnd)); !errors.Is(err, ErrMalformed) {
+		t.Errorf("garbled partial signature: got %v, want ErrMalformed", err)
+	}
+}
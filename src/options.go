@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// Optional settings accepted by the encryption routines.
+type cryptOption func(*cryptConfig)
+
+type cryptConfig struct {
+	padding       byte      // padding scheme applied to the plaintext, 0 for none
+	compress      bool      // compress the plaintext before encryption
+	noCompress    bool      // per-call opt-out, overrides compress
+	maxInflate    int64     // upper bound on decompressed plaintext size
+	rand          io.Reader // source of randomness for nonces and ephemeral keys
+	hideRecipient bool      // omit the recipient key fingerprint from EC cryptograms
+}
+
+// Collects options into a configuration with default values.
+func newCryptConfig(opts []cryptOption) *cryptConfig {
+	cfg := &cryptConfig{maxInflate: maxDecompressedSize, rand: rand.Reader}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.noCompress {
+		cfg.compress = false
+	}
+	return cfg
+}
+
+// Flags to record in a cryptogram produced under this configuration.
+func (c *cryptConfig) flags() byte {
+	flags := c.padding
+	if c.compress {
+		flags |= flagDeflate
+	}
+	return flags
+}
+
+// Applies compression and padding to a plaintext prior to encryption.
+func (c *cryptConfig) encode(m []byte) []byte {
+	if c.compress {
+		m = compressMessage(m)
+	}
+	return padMessage(m, c.padding)
+}
+
+// Every flag understood by this version of the cryptogram format.
+const knownFlags = padMask | flagDeflate
+
+// Rejects cryptograms that record transforms this version cannot undo.
+func checkFlags(flags byte) error {
+	if flags&^knownFlags != 0 {
+		return ErrUnsupportedVersion
+	}
+	if flags&padMask == padMask {
+		return wrapErr(ErrMalformed, errors.New("conflicting padding flags"))
+	}
+	return nil
+}
+
+/*
+Reverses the transforms recorded in flags on an authenticated plaintext.
+Must only be called once the tag has been verified.
+*/
+func (c *cryptConfig) decode(m []byte, flags byte) ([]byte, error) {
+	m, err := unpadMessage(m, flags&padMask)
+	if err != nil {
+		return nil, wrapErr(ErrMalformed, err)
+	}
+	if flags&flagDeflate != 0 {
+		m, err = decompressMessage(m, c.maxInflate)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Pads the plaintext with the given scheme (flagPadme or flagPow2) before encryption.
+func withPadding(scheme byte) cryptOption {
+	return func(c *cryptConfig) {
+		if scheme == flagPadme || scheme == flagPow2 {
+			c.padding = scheme
+		}
+	}
+}
+
+// Compresses the plaintext with DEFLATE before encryption.
+func withCompression(enable bool) cryptOption {
+	return func(c *cryptConfig) { c.compress = enable }
+}
+
+// Disables compression for this call regardless of other options. Use for
+// plaintexts that mix secrets with attacker-influenced data.
+func withoutCompression() cryptOption {
+	return func(c *cryptConfig) { c.noCompress = true }
+}
+
+// Overrides the limit on the size of a decompressed plaintext. A negative limit is rejected and the default kept.
+func withMaxDecompressedSize(limit int64) cryptOption {
+	return func(c *cryptConfig) {
+		if limit >= 0 {
+			c.maxInflate = limit
+		}
+	}
+}
+
+// Draws nonces and ephemeral keys from rng instead of crypto/rand. Intended
+// for reproducible test vectors; rng must be unpredictable in production.
+func withRand(rng io.Reader) cryptOption {
+	return func(c *cryptConfig) {
+		if rng != nil {
+			c.rand = rng
+		}
+	}
+}
+
+// Omits the recipient key fingerprint from EC cryptograms. Recipients
+// must then try each of their private keys in turn.
+func withHiddenRecipient() cryptOption {
+	return func(c *cryptConfig) { c.hideRecipient = true }
+}
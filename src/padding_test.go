@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPaddingRoundTrip(t *testing.T) {
+	for _, scheme := range []byte{flagPadme, flagPow2} {
+		for L := 0; L < 600; L++ {
+			m := bytes.Repeat([]byte{0x80}, L)
+			p := padMessage(m, scheme)
+			if len(p) != paddedLength(L+1, scheme) {
+				t.Fatalf("scheme %d, %d bytes: padded to %d", scheme, L, len(p))
+			}
+			got, err := unpadMessage(p, scheme)
+			if err != nil || !bytes.Equal(got, m) {
+				t.Fatalf("scheme %d, %d bytes: did not round trip: %v", scheme, L, err)
+			}
+		}
+	}
+}
+
+func TestUnpadRejectsBadPadding(t *testing.T) {
+	for _, scheme := range []byte{flagPadme, flagPow2} {
+		m := []byte("status: ok, with enough text to need a few padding bytes")
+		p := padMessage(m, scheme)
+		marker := len(m)
+		bad := map[string][]byte{
+			"empty":            {},
+			"all zero":         make([]byte, len(p)),
+			"no marker":        append(append([]byte{}, m...), make([]byte, len(p)-len(m))...),
+			"wrong marker":     append(append(append([]byte{}, p[:marker]...), 0x01), p[marker+1:]...),
+			"nonzero tail":     append(append([]byte{}, p[:len(p)-1]...), 0x01),
+			"extra zero":       append(append([]byte{}, p...), 0),
+			"missing zero":     p[:len(p)-1],
+			"marker too early": append(append(append([]byte{}, m[:4]...), 0x80), make([]byte, len(p)-5)...),
+		}
+		for name, b := range bad {
+			if _, err := unpadMessage(b, scheme); err == nil {
+				t.Errorf("scheme %d, %s: padding accepted", scheme, name)
+			}
+		}
+	}
+}
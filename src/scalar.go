@@ -0,0 +1,327 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"math/big"
+	"math/bits"
+)
+
+/*
+Scalar is an integer modulo the prime order of a curve's generator, r for
+E521 unless the scalar was created from another curve's scalarField.
+
+Values are held in Montgomery form, a*2^576 mod r, in nine little-endian
+64 bit limbs. Add, Sub, Mul and Invert run in time independent of their
+operands. Every constructor reduces its input, so a Scalar is always in
+[0, r) and has exactly one encoding. The zero value is 0 mod r of E521.
+*/
+type Scalar struct {
+	f *scalarField
+	l [scalarLimbs]uint64
+}
+
+const (
+	scalarLimbs = 9
+	// Length in bytes of the canonical big-endian E521 scalar encoding.
+	ScalarSize = 66
+	// Bytes of randomness read per scalar; the excess over |r| makes the bias negligible.
+	scalarWideSize = 128
+)
+
+// Montgomery constants for one group order, precomputed with big.Int.
+type scalarField struct {
+	order big.Int
+	size  int                 // length of the canonical encoding in bytes
+	r     [scalarLimbs]uint64 // the order as limbs
+	rr    [scalarLimbs]uint64 // 2^1152 mod order
+	nInv  uint64              // -1/order mod 2^64
+	word  [scalarLimbs]uint64 // 2^64 in Montgomery form, the radix step of SetUniformBytes
+	one   [scalarLimbs]uint64 // 1 in Montgomery form
+}
+
+var twoTo64 = new(big.Int).Lsh(big.NewInt(1), 64)
+
+// Scalars modulo the order r of the E521 generator.
+var e521Scalars = newScalarField(func() *big.Int { r := new(E521).getR(); return &r }(), ScalarSize)
+
+// The E521 order r, for code that still needs it as a big.Int.
+var scalarOrder = e521Scalars.order
+
+// Precomputes the Montgomery constants for an odd order below 2^574.
+func newScalarField(order *big.Int, size int) *scalarField {
+	f := &scalarField{size: size}
+	f.order.Set(order)
+	f.r = scalarLimbsOf(order)
+	f.rr = scalarLimbsOf(f.montOf(new(big.Int).Lsh(big.NewInt(1), 64*scalarLimbs)))
+	f.nInv = -new(big.Int).ModInverse(new(big.Int).SetUint64(f.r[0]), twoTo64).Uint64()
+	f.word = scalarLimbsOf(f.montOf(twoTo64))
+	f.one = scalarLimbsOf(f.montOf(big.NewInt(1)))
+	return f
+}
+
+// Returns x*2^576 mod order, the Montgomery form of x as an integer.
+func (f *scalarField) montOf(x *big.Int) *big.Int {
+	m := new(big.Int).Lsh(x, 64*scalarLimbs)
+	return m.Mod(m, &f.order)
+}
+
+// Returns a new scalar of this field set to zero.
+func (f *scalarField) zero() *Scalar { return &Scalar{f: f} }
+
+// Returns a new scalar of this field set to the small integer v.
+func (f *scalarField) fromUint64(v uint64) *Scalar {
+	s := f.zero()
+	s.l[0] = v
+	f.montMul(&s.l, &s.l, &f.rr)
+	return s
+}
+
+// Returns the field of s, defaulting to E521 for the zero value.
+func (s *Scalar) field() *scalarField {
+	if s.f == nil {
+		return e521Scalars
+	}
+	return s.f
+}
+
+// Splits a non-negative x < 2^576 into little-endian limbs.
+func scalarLimbsOf(x *big.Int) [scalarLimbs]uint64 {
+	var out [scalarLimbs]uint64
+	buf := make([]byte, 8*scalarLimbs)
+	x.FillBytes(buf)
+	for i := range out {
+		for j := 0; j < 8; j++ {
+			out[i] |= uint64(buf[len(buf)-1-8*i-j]) << (8 * j)
+		}
+	}
+	return out
+}
+
+// Returns hi, lo of a*b + c + d, which cannot overflow 128 bits.
+func madd(a, b, c, d uint64) (uint64, uint64) {
+	hi, lo := bits.Mul64(a, b)
+	var cc uint64
+	lo, cc = bits.Add64(lo, c, 0)
+	hi += cc
+	lo, cc = bits.Add64(lo, d, 0)
+	hi += cc
+	return hi, lo
+}
+
+/*
+Montgomery multiplication, out = a*b/2^576 mod r, by coarsely integrated
+operand scanning. As r is far below 2^576 the intermediate stays below 2r
+and one masked subtraction of r completes the reduction.
+*/
+func (f *scalarField) montMul(out, a, b *[scalarLimbs]uint64) {
+	var t [scalarLimbs + 2]uint64
+	for i := 0; i < scalarLimbs; i++ {
+		var c uint64
+		for j := 0; j < scalarLimbs; j++ {
+			c, t[j] = madd(a[j], b[i], t[j], c)
+		}
+		var cc uint64
+		t[scalarLimbs], cc = bits.Add64(t[scalarLimbs], c, 0)
+		t[scalarLimbs+1] = cc
+
+		m := t[0] * f.nInv
+		c, _ = madd(m, f.r[0], t[0], 0)
+		for j := 1; j < scalarLimbs; j++ {
+			c, t[j-1] = madd(m, f.r[j], t[j], c)
+		}
+		t[scalarLimbs-1], cc = bits.Add64(t[scalarLimbs], c, 0)
+		t[scalarLimbs] = t[scalarLimbs+1] + cc
+	}
+	var res [scalarLimbs]uint64
+	copy(res[:], t[:scalarLimbs])
+	f.condSubR(out, &res, t[scalarLimbs])
+}
+
+// Sets out = a - r if a (with high word hi) is at least r, else out = a.
+func (f *scalarField) condSubR(out, a *[scalarLimbs]uint64, hi uint64) {
+	var d [scalarLimbs]uint64
+	var b uint64
+	for i := range d {
+		d[i], b = bits.Sub64(a[i], f.r[i], b)
+	}
+	_, b = bits.Sub64(hi, 0, b)
+	// b == 1 means a < r: keep a
+	mask := -b
+	for i := range out {
+		out[i] = (a[i] & mask) | (d[i] &^ mask)
+	}
+}
+
+// Returns a new E521 scalar set to the small integer v.
+func scalarFromUint64(v uint64) *Scalar { return e521Scalars.fromUint64(v) }
+
+// Sets s = a + b mod r and returns s.
+func (s *Scalar) Add(a, b *Scalar) *Scalar {
+	f := a.field()
+	var sum [scalarLimbs]uint64
+	var c uint64
+	for i := range sum {
+		sum[i], c = bits.Add64(a.l[i], b.l[i], c)
+	}
+	f.condSubR(&s.l, &sum, c)
+	s.f = f
+	return s
+}
+
+// Sets s = a - b mod r and returns s.
+func (s *Scalar) Sub(a, b *Scalar) *Scalar {
+	f := a.field()
+	var diff [scalarLimbs]uint64
+	var borrow uint64
+	for i := range diff {
+		diff[i], borrow = bits.Sub64(a.l[i], b.l[i], borrow)
+	}
+	// add r back if the subtraction wrapped
+	mask := -borrow
+	var c uint64
+	for i := range s.l {
+		s.l[i], c = bits.Add64(diff[i], f.r[i]&mask, c)
+	}
+	s.f = f
+	return s
+}
+
+// Sets s = -a mod r and returns s.
+func (s *Scalar) Neg(a *Scalar) *Scalar { return s.Sub(a.field().zero(), a) }
+
+// Sets s = a * b mod r and returns s.
+func (s *Scalar) Mul(a, b *Scalar) *Scalar {
+	f := a.field()
+	f.montMul(&s.l, &a.l, &b.l)
+	s.f = f
+	return s
+}
+
+/*
+Sets s = 1/a mod r, computed as a^(r-2), and returns s. The exponent is
+public, so branching on its bits leaks nothing about a. The inverse of 0
+is 0.
+*/
+func (s *Scalar) Invert(a *Scalar) *Scalar {
+	f := a.field()
+	e := new(big.Int).Sub(&f.order, big.NewInt(2))
+	acc := Scalar{f: f, l: f.one}
+	base := *a
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		acc.Mul(&acc, &acc)
+		if e.Bit(i) == 1 {
+			acc.Mul(&acc, &base)
+		}
+	}
+	*s = acc
+	return s
+}
+
+// Returns 1 if s == t and 0 otherwise.
+func (s *Scalar) Equal(t *Scalar) int {
+	var acc uint64
+	for i := range s.l {
+		acc |= s.l[i] ^ t.l[i]
+	}
+	return subtle.ConstantTimeEq(int32(acc>>32|acc&0xffffffff), 0)
+}
+
+// Returns 1 if s == 0 and 0 otherwise.
+func (s *Scalar) IsZero() int { return s.Equal(&Scalar{}) }
+
+// Returns the canonical big-endian encoding of s, ScalarSize bytes for E521.
+func (s *Scalar) Bytes() []byte {
+	f := s.field()
+	var one, plain [scalarLimbs]uint64
+	one[0] = 1
+	f.montMul(&plain, &s.l, &one)
+	out := make([]byte, f.size)
+	for i, limb := range plain {
+		for j := 0; j < 8 && 8*i+j < f.size; j++ {
+			out[f.size-1-8*i-j] = byte(limb >> (8 * j))
+		}
+	}
+	return out
+}
+
+/*
+Sets s from a canonical big-endian encoding, ScalarSize bytes for E521.
+Returns an error wrapping ErrMalformed if the length is wrong or the value
+is not below r.
+*/
+func (s *Scalar) SetCanonicalBytes(b []byte) (*Scalar, error) {
+	f := s.field()
+	if len(b) != f.size {
+		return nil, wrapErr(ErrMalformed, errors.New("invalid scalar length"))
+	}
+	var x [scalarLimbs]uint64
+	for i := 0; i < f.size; i++ {
+		x[i/8] |= uint64(b[f.size-1-i]) << (8 * (i % 8))
+	}
+	var d [scalarLimbs]uint64
+	var borrow uint64
+	for i := range d {
+		d[i], borrow = bits.Sub64(x[i], f.r[i], borrow)
+	}
+	if borrow == 0 {
+		return nil, wrapErr(ErrMalformed, errors.New("scalar is not reduced"))
+	}
+	f.montMul(&s.l, &x, &f.rr)
+	s.f = f
+	return s, nil
+}
+
+/*
+Sets s to b mod r, reading b as a big-endian integer of any length, and
+returns s. Intended for 512 and 1024 bit KMAC or DRBG outputs, where the
+wide input makes the result statistically uniform. Runs in time that
+depends only on len(b).
+*/
+func (s *Scalar) SetUniformBytes(b []byte) *Scalar {
+	f := s.field()
+	// left pad to whole words, then Horner's rule: acc = acc*2^64 + word
+	padded := make([]byte, (len(b)+7)/8*8)
+	copy(padded[len(padded)-len(b):], b)
+	acc, word := f.zero(), f.zero()
+	step := Scalar{f: f, l: f.word}
+	for i := 0; i < len(padded); i += 8 {
+		var w [scalarLimbs]uint64
+		for j := 0; j < 8; j++ {
+			w[0] = w[0]<<8 | uint64(padded[i+j])
+		}
+		f.montMul(&word.l, &w, &f.rr)
+		acc.Mul(acc, &step)
+		acc.Add(acc, word)
+	}
+	zeroize(padded)
+	*s = *acc
+	return s
+}
+
+// Returns a uniformly random E521 scalar read from rng.
+func randomScalar(rng io.Reader) (*Scalar, error) {
+	b, err := readRandomBytes(rng, scalarWideSize)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroize(b)
+	return new(Scalar).SetUniformBytes(b), nil
+}
+
+// Sets s = x mod r for any integer x and returns s. For use at API boundaries.
+func (s *Scalar) SetBigInt(x *big.Int) *Scalar {
+	f := s.field()
+	buf := make([]byte, f.size)
+	new(big.Int).Mod(x, &f.order).FillBytes(buf)
+	s.SetCanonicalBytes(buf)
+	zeroize(buf)
+	return s
+}
+
+// Returns the value of s as a big.Int in [0, r). For use at API boundaries
+// such as point multiplication.
+func (s *Scalar) BigInt() *big.Int {
+	return new(big.Int).SetBytes(s.Bytes())
+}
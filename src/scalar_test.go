@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// Edge values plus random scalars below r.
+func scalarTestValues(n int) []*big.Int {
+	rng := rand.New(rand.NewSource(519))
+	vals := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(2), new(big.Int).Sub(&scalarOrder, big.NewInt(1)),
+		new(big.Int).Rsh(&scalarOrder, 1), new(big.Int).Lsh(big.NewInt(1), 518)}
+	for i := 0; i < n; i++ {
+		vals = append(vals, new(big.Int).Rand(rng, &scalarOrder))
+	}
+	return vals
+}
+
+func TestScalarMatchesBigInt(t *testing.T) {
+	vals := scalarTestValues(30)
+	for _, a := range vals {
+		sa := new(Scalar).SetBigInt(a)
+		if sa.BigInt().Cmp(a) != 0 {
+			t.Fatalf("round trip of %x gave %x", a, sa.BigInt())
+		}
+		for _, b := range vals {
+			sb := new(Scalar).SetBigInt(b)
+			check := func(op string, got *Scalar, want *big.Int) {
+				t.Helper()
+				if got.BigInt().Cmp(want.Mod(want, &scalarOrder)) != 0 {
+					t.Fatalf("%s(%x, %x) = %x, want %x", op, a, b, got.BigInt(), want)
+				}
+			}
+			check("add", new(Scalar).Add(sa, sb), new(big.Int).Add(a, b))
+			check("sub", new(Scalar).Sub(sa, sb), new(big.Int).Sub(a, b))
+			check("mul", new(Scalar).Mul(sa, sb), new(big.Int).Mul(a, b))
+		}
+		if a.Sign() != 0 {
+			inv := new(Scalar).Invert(sa)
+			if new(Scalar).Mul(inv, sa).Equal(scalarFromUint64(1)) != 1 {
+				t.Fatalf("invert(%x) is not an inverse", a)
+			}
+		}
+	}
+}
+
+func TestScalarEncoding(t *testing.T) {
+	for _, a := range scalarTestValues(10) {
+		want := make([]byte, ScalarSize)
+		a.FillBytes(want)
+		s, err := new(Scalar).SetCanonicalBytes(want)
+		if err != nil || !bytes.Equal(s.Bytes(), want) {
+			t.Fatalf("canonical encoding of %x did not round trip: %v", a, err)
+		}
+	}
+	for _, bad := range []*big.Int{&scalarOrder, new(big.Int).Lsh(big.NewInt(1), 527)} {
+		enc := make([]byte, ScalarSize)
+		bad.FillBytes(enc)
+		if _, err := new(Scalar).SetCanonicalBytes(enc); !errors.Is(err, ErrMalformed) {
+			t.Errorf("non-canonical %x: got %v, want ErrMalformed", bad, err)
+		}
+	}
+	if _, err := new(Scalar).SetCanonicalBytes(make([]byte, 65)); !errors.Is(err, ErrMalformed) {
+		t.Errorf("short encoding: got %v, want ErrMalformed", err)
+	}
+}
+
+func TestScalarWideReduction(t *testing.T) {
+	rng := rand.New(rand.NewSource(1024))
+	for _, size := range []int{0, 1, 7, 64, 65, 128, 200} {
+		b := make([]byte, size)
+		rng.Read(b)
+		want := new(big.Int).Mod(new(big.Int).SetBytes(b), &scalarOrder)
+		if got := new(Scalar).SetUniformBytes(b).BigInt(); got.Cmp(want) != 0 {
+			t.Errorf("%d byte input reduced to %x, want %x", size, got, want)
+		}
+	}
+	if _, err := randomScalar(bytes.NewReader(make([]byte, scalarWideSize-1))); err == nil {
+		t.Error("randomScalar accepted a short randomness source")
+	}
+}
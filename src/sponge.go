@@ -43,3 +43,95 @@ func padTenOne(X []byte, rateInBytes int) []byte {
 	padded[len(X)+q-1] = byte(0x80)
 	return padded
 }
+
+/*
+A Keccak sponge that absorbs its input incrementally, so that files and
+other streams can be hashed without holding them in memory. Write absorbs
+input; the first Read pads the input with the domain separation bits and
+the pad10*1 rule of FIPS 202 and switches the sponge to squeezing.
+*/
+type keccakSponge struct {
+	a         [25]uint64
+	buf       []byte // absorbed bytes not yet making up a full block, or squeezed bytes not yet read
+	rate      int    // block size in bytes
+	ds        byte   // domain separation bits followed by the first padding bit
+	squeezing bool
+}
+
+/*
+Returns a sponge computing cSHAKE256 with function name N and customization
+string S, or SHAKE256 if both are empty (NIST SP 800-185 section 3.3).
+*/
+func newCSHAKE256(N, S string) *keccakSponge {
+	if N == "" && S == "" {
+		return &keccakSponge{rate: 136, ds: 0x1F}
+	}
+	k := &keccakSponge{rate: 136, ds: 0x04}
+	k.Write(bytepad(append(encodeString([]byte(N)), encodeString([]byte(S))...), k.rate))
+	return k
+}
+
+// XORs one rate sized block into the state and applies the permutation.
+func (k *keccakSponge) absorbBlock(block []byte) {
+	for i := 0; i < k.rate/8; i++ {
+		k.a[i] ^= BytesToLane(block, uint64(8*i))
+	}
+	KeccakF1600(&k.a)
+}
+
+// Absorbs p. Never fails, but panics if called after Read.
+func (k *keccakSponge) Write(p []byte) (int, error) {
+	if k.squeezing {
+		panic("keccakSponge: Write after Read")
+	}
+	n := len(p)
+	if len(k.buf) > 0 {
+		fill := k.rate - len(k.buf)
+		if len(p) < fill {
+			k.buf = append(k.buf, p...)
+			return n, nil
+		}
+		k.buf = append(k.buf, p[:fill]...)
+		k.absorbBlock(k.buf)
+		k.buf = k.buf[:0]
+		p = p[fill:]
+	}
+	for len(p) >= k.rate {
+		k.absorbBlock(p[:k.rate])
+		p = p[k.rate:]
+	}
+	k.buf = append(k.buf, p...)
+	return n, nil
+}
+
+// Squeezes len(out) bytes of output. Never fails.
+func (k *keccakSponge) Read(out []byte) (int, error) {
+	if !k.squeezing {
+		block := make([]byte, k.rate)
+		copy(block, k.buf)
+		block[len(k.buf)] ^= k.ds
+		block[k.rate-1] ^= 0x80
+		k.absorbBlock(block)
+		k.buf = nil
+		k.squeezing = true
+	}
+	n := len(out)
+	for len(out) > 0 {
+		if len(k.buf) == 0 {
+			lanes := append([]uint64{}, k.a[:k.rate/8]...)
+			k.buf = StateToByteArray(&lanes, 8*k.rate)
+			KeccakF1600(&k.a)
+		}
+		c := copy(out, k.buf)
+		k.buf = k.buf[c:]
+		out = out[c:]
+	}
+	return n, nil
+}
+
+// Returns the next bitLength/8 bytes of output.
+func (k *keccakSponge) Sum(bitLength int) []byte {
+	out := make([]byte, bitLength/8)
+	k.Read(out)
+	return out
+}
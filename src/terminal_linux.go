@@ -0,0 +1,26 @@
+//go:build linux
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// Reports whether fd refers to a terminal.
+func isTerminal(fd uintptr) bool {
+	_, err := unix.IoctlGetTermios(int(fd), unix.TCGETS)
+	return err == nil
+}
+
+// Turns off echo on the terminal fd and returns a function that restores its previous state.
+func disableEcho(fd uintptr) (func(), error) {
+	old, err := unix.IoctlGetTermios(int(fd), unix.TCGETS)
+	if err != nil {
+		return nil, err
+	}
+	t := *old
+	t.Lflag &^= unix.ECHO
+	t.Lflag |= unix.ICANON | unix.ISIG
+	if err := unix.IoctlSetTermios(int(fd), unix.TCSETS, &t); err != nil {
+		return nil, err
+	}
+	return func() { unix.IoctlSetTermios(int(fd), unix.TCSETS, old) }, nil
+}
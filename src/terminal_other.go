@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// Terminal modes are only handled on Linux; elsewhere passphrases are read as plain lines.
+func isTerminal(fd uintptr) bool { return false }
+
+func disableEcho(fd uintptr) (func(), error) {
+	return nil, errors.New("cannot turn off terminal echo on this platform")
+}
@@ -14,6 +14,10 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/gotk3/gotk3/gtk"
 	"github.com/lukechampine/fastxor"
@@ -29,6 +33,16 @@ func generateRandomBytes(size int) []byte {
 	return b
 }
 
+// reads exactly size bytes from the randomness source rng. Unlike
+// generateRandomBytes, a short read is reported rather than ignored.
+func readRandomBytes(rng io.Reader, size int) ([]byte, error) {
+	b := make([]byte, size)
+	if _, err := io.ReadFull(rng, b); err != nil {
+		return nil, fmt.Errorf("unable to read randomness: %w", err)
+	}
+	return b, nil
+}
+
 // Converts uint64 arrays to hex strings
 func StateArrayToHexString(input [25]uint64) string {
 	var output string
@@ -95,6 +109,22 @@ func XorBytes(a, b []byte) []byte {
 	return dst
 }
 
+/*
+Reports whether data can be shown in the notepad without loss: it must be
+valid UTF-8 and free of control characters other than common whitespace.
+*/
+func isDisplayableText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, r := range string(data) {
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
 /* SUPPORTING FUNCTIONS FOR VIEW */
 
 // function to check if pwds match
@@ -126,6 +156,33 @@ func getScrollableTextArea(ctx *WindowCtx, textForBuffer string) gtk.ScrolledWin
 	return *scrollableTextArea
 }
 
+// Returns the bytes of the loaded file in file mode, otherwise the notepad text.
+func (ctx *WindowCtx) inputBytes() ([]byte, error) {
+	if ctx.fileMode && ctx.loadedFile != nil {
+		return os.ReadFile(ctx.loadedFile.Name())
+	}
+	text, _ := ctx.notePad.GetText(ctx.notePad.GetStartIter(), ctx.notePad.GetEndIter(), false)
+	return []byte(text), nil
+}
+
+// Curve selected for new keys, E521 unless Ed448 was chosen in the Options menu.
+func (ctx *WindowCtx) keyCurve() Curve {
+	if ctx.curve == nil {
+		return E521Curve
+	}
+	return ctx.curve
+}
+
+// Curve to sign on: that of the loaded key, otherwise the one selected for new keys.
+func (ctx *WindowCtx) signingCurve() Curve {
+	if ctx.loadedKey != nil {
+		if curve, err := curveByName(ctx.loadedKey.Curve); err == nil {
+			return curve
+		}
+	}
+	return ctx.keyCurve()
+}
+
 // Resets context to initial state
 func (ctx *WindowCtx) Reset() {
 	ctx.notePad.SetText("")
@@ -195,7 +252,7 @@ func decodeSymCryptogram(cg_dec *[]byte) (*SymCryptogram, error) {
 	dec := gob.NewDecoder(buf)
 	var p2 SymCryptogram
 	if err := dec.Decode(&p2); err != nil {
-		return nil, errors.New("failed to decrypt")
+		return nil, wrapErr(ErrMalformed, err)
 	}
 	return &p2, nil
 }
@@ -206,18 +263,28 @@ func decodeECCryptogram(cg_dec *[]byte) (*ECCryptogram, error) {
 	dec := gob.NewDecoder(buf)
 	var p2 ECCryptogram
 	if err := dec.Decode(&p2); err != nil {
-		return nil, errors.New("failed to decrypt")
+		return nil, wrapErr(ErrMalformed, err)
+	}
+	if _, err := p2.nonce(); err != nil {
+		return nil, err
 	}
 	return &p2, nil
 }
 
-// Parses a cryptogram from a string
+// Parses a signature, rejecting unknown curves and non-canonical scalars
 func decodeSignature(cg_dec *[]byte) (*Signature, error) {
 	buf := bytes.NewBuffer(*cg_dec)
 	dec := gob.NewDecoder(buf)
 	var p2 Signature
 	if err := dec.Decode(&p2); err != nil {
-		return nil, errors.New("failed to decrypt")
+		return nil, wrapErr(ErrMalformed, err)
+	}
+	curve, err := curveByID(p2.C)
+	if err != nil {
+		return nil, err
+	}
+	if err := p2.checkCanonical(curve); err != nil {
+		return nil, err
 	}
 	return &p2, nil
 }
@@ -237,9 +304,12 @@ func exportPublicKey(ctx *WindowCtx) {
 	if ctx.loadedKey != nil {
 		key := ctx.loadedKey
 		//generate different IDs for public and private keys
-		randomID := generateRandomBytes(200)
-		randomID = append(randomID, []byte(key.Id)...) //Delim Suffix for key ID
-		key.Id = hex.EncodeToString(SpongeSqueeze(SpongeAbsorb(&randomID, 256), 48, 136))
+		id, err := newKeyID(rand.Reader, []byte(key.Id))
+		if err != nil {
+			ctx.updateStatus("Export cancelled - " + err.Error())
+			return
+		}
+		key.Id = id
 		key.PrivKey = ""
 		key.KeyType = "PUBLIC"
 		KeyToJSON(key)
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+)
+
+// Adds the encodings of a few valid artifacts to a fuzz corpus.
+func addSeeds(f *testing.F, produce func(msg []byte) (*[]byte, error)) {
+	for _, msg := range [][]byte{{}, []byte("message"), make([]byte, 300)} {
+		raw, err := produce(msg)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(*raw)
+	}
+	f.Add([]byte{})
+}
+
+func FuzzDecodeSymCryptogram(f *testing.F) {
+	addSeeds(f, func(msg []byte) (*[]byte, error) {
+		return encryptWithPW([]byte("pw"), &msg, withPadding(flagPow2), withCompression(true))
+	})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cg, err := decodeSymCryptogram(&data)
+		if err != nil {
+			return
+		}
+		decryptWithPW([]byte("pw"), cg)
+	})
+}
+
+func FuzzDecodeECCryptogram(f *testing.F) {
+	V := testPublicKey(f, "pw")
+	addSeeds(f, func(msg []byte) (*[]byte, error) { return encryptWithKey(V, &msg) })
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cg, err := decodeECCryptogram(&data)
+		if err != nil {
+			return
+		}
+		decryptWithKey([]byte("pw"), cg)
+	})
+}
+
+func FuzzDecodeSignature(f *testing.F) {
+	V := testPublicKey(f, "pw")
+	addSeeds(f, func(msg []byte) (*[]byte, error) { return signWithKey([]byte("pw"), &msg) })
+	f.Fuzz(func(t *testing.T, data []byte) {
+		sig, err := decodeSignature(&data)
+		if err != nil {
+			return
+		}
+		verifyMessage(V, sig, &sig.M)
+		VerifyBatch([]SignedItem{{PubKey: V, Message: sig.M, Sig: sig}})
+	})
+}
@@ -16,22 +16,30 @@ Fixed holds all widgets so that they can be placed at precise pixels.
 The style of the window is set by style.css.
 */
 type WindowCtx struct {
-	win          *gtk.Window      // Main window containing fixed container
-	fixed        *gtk.Fixed       // Fixed allows for precise arbitrary placement of widgets
-	loadedFile   *os.File         // Represents the selected file either dropped in window or selected from chooser
-	notePad      *gtk.TextBuffer  // The text area displaying input and output
-	initialState bool             // Signals if window is waiting for user input, can also be used to cancel running ops
-	status       *gtk.Label       // Outputs operation status and error messages
-	keytable     *KeyTable        // A table storing all imported keys
-	loadedKey    *KeyObj          // The key to be used for any asymmetric encryptions
-	fileMode     bool             // Determines whether to process a loaded file or notepad text
-	progressBar  *gtk.ProgressBar // A bar to display status of ongoing operations
-	buttons      *[]gtk.Button    // A list of pointers to all buttons added to the window
+	win           *gtk.Window      // Main window containing fixed container
+	fixed         *gtk.Fixed       // Fixed allows for precise arbitrary placement of widgets
+	loadedFile    *os.File         // Represents the selected file either dropped in window or selected from chooser
+	notePad       *gtk.TextBuffer  // The text area displaying input and output
+	initialState  bool             // Signals if window is waiting for user input, can also be used to cancel running ops
+	status        *gtk.Label       // Outputs operation status and error messages
+	keytable      *KeyTable        // A table storing all imported keys
+	loadedKey     *KeyObj          // The key to be used for any asymmetric encryptions
+	fileMode      bool             // Determines whether to process a loaded file or notepad text
+	progressBar   *gtk.ProgressBar // A bar to display status of ongoing operations
+	buttons       *[]gtk.Button    // A list of pointers to all buttons added to the window
+	padding       byte             // Padding scheme applied to plaintext before encryption, 0 for none
+	compress      bool             // Compress plaintext with DEFLATE before encryption
+	hideRecipient bool             // Omit the recipient key ID from EC cryptograms
+	clearSign     bool             // Keep signed messages readable above the signature
+	curve         Curve            // Curve for newly generated keys and unkeyed signatures, E521 if nil
 }
 
 // Entry point
 func main() {
-	gtk.Init(nil)
+	if isCLICommand(os.Args[1:]) {
+		os.Exit(runCLI(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
+	gtk.Init(&os.Args)
 	window := initialize()
 	settings, _ := gtk.SettingsGetDefault()
 	err := settings.SetProperty("gtk-application-prefer-dark-theme", true) //try to default to dark theme
@@ -167,16 +175,21 @@ func setupMenuBar(ctx *WindowCtx) {
 	menubar, _ := gtk.MenuBarNew()
 	fileMenu, _ := gtk.MenuItemNewWithLabel("File")
 	keysMenu, _ := gtk.MenuItemNewWithLabel("Keys")
+	optionsMenu, _ := gtk.MenuItemNewWithLabel("Options")
 	keysDropDown, _ := gtk.MenuNew()
 	fileDropDown, _ := gtk.MenuNew()
+	optionsDropDown, _ := gtk.MenuNew()
 
 	keysMenu.SetSubmenu(keysDropDown)
 	fileMenu.SetSubmenu(fileDropDown)
+	optionsMenu.SetSubmenu(optionsDropDown)
 
 	keysImport, _ := gtk.MenuItemNewWithLabel("Import")
 	keysExport, _ := gtk.MenuItemNewWithLabel("Export")
 	fileLoad, _ := gtk.MenuItemNewWithLabel("Load File")
 	fileSave, _ := gtk.MenuItemNewWithLabel("Save File")
+	fileSign, _ := gtk.MenuItemNewWithLabel("Sign File...")
+	fileVerify, _ := gtk.MenuItemNewWithLabel("Verify File...")
 	help, _ := gtk.MenuItemNewWithLabel("How To Use")
 	exit, _ := gtk.MenuItemNewWithLabel("Exit")
 
@@ -184,16 +197,84 @@ func setupMenuBar(ctx *WindowCtx) {
 	keysImport.Connect("activate", func() { importKeyDialog(ctx) })
 	keysExport.Connect("activate", func() { exportPrivateKey(ctx) })
 
+	//detached signatures over files, stored next to them as .sig files
+	fileSign.Connect("activate", func() { signFileWithKey(ctx) })
+	fileVerify.Connect("activate", func() { verifyFileWithKey(ctx) })
+
 	keysDropDown.Append(keysImport)
 	keysDropDown.Append(keysExport)
 
+	//hide message length by padding plaintext before encryption
+	padMessages, _ := gtk.CheckMenuItemNewWithLabel("Pad encrypted messages")
+	padMessages.Connect("toggled", func() {
+		if padMessages.GetActive() {
+			ctx.padding = flagPadme
+			ctx.updateStatus("message padding enabled")
+		} else {
+			ctx.padding = 0
+			ctx.updateStatus("message padding disabled")
+		}
+	})
+	optionsDropDown.Append(padMessages)
+
+	//shrink large text payloads before encrypting them
+	compressMessages, _ := gtk.CheckMenuItemNewWithLabel("Compress encrypted messages")
+	compressMessages.Connect("toggled", func() {
+		ctx.compress = compressMessages.GetActive()
+		if ctx.compress {
+			ctx.updateStatus("message compression enabled")
+		} else {
+			ctx.updateStatus("message compression disabled")
+		}
+	})
+	optionsDropDown.Append(compressMessages)
+
+	//leave the recipient key ID out of EC cryptograms
+	hideRecipient, _ := gtk.CheckMenuItemNewWithLabel("Hide recipient key ID")
+	hideRecipient.Connect("toggled", func() {
+		ctx.hideRecipient = hideRecipient.GetActive()
+		if ctx.hideRecipient {
+			ctx.updateStatus("recipient key ID hidden")
+		} else {
+			ctx.updateStatus("recipient key ID shown")
+		}
+	})
+	optionsDropDown.Append(hideRecipient)
+
+	//keep signed text readable instead of embedding it in the signature
+	clearSignMessages, _ := gtk.CheckMenuItemNewWithLabel("Clear-sign messages")
+	clearSignMessages.Connect("toggled", func() {
+		ctx.clearSign = clearSignMessages.GetActive()
+		if ctx.clearSign {
+			ctx.updateStatus("clear-signing enabled")
+		} else {
+			ctx.updateStatus("clear-signing disabled")
+		}
+	})
+	optionsDropDown.Append(clearSignMessages)
+
+	//generate keys on Ed448-Goldilocks instead of E521
+	useEd448, _ := gtk.CheckMenuItemNewWithLabel("Generate Ed448 keys")
+	useEd448.Connect("toggled", func() {
+		if useEd448.GetActive() {
+			ctx.curve = Ed448Curve
+		} else {
+			ctx.curve = E521Curve
+		}
+		ctx.updateStatus("new keys use curve " + ctx.keyCurve().Name())
+	})
+	optionsDropDown.Append(useEd448)
+
 	fileDropDown.Append(fileLoad)
 	fileDropDown.Append(fileSave)
+	fileDropDown.Append(fileSign)
+	fileDropDown.Append(fileVerify)
 	fileDropDown.Append(help)
 	fileDropDown.Append(exit)
 
 	menubar.Append(fileMenu)
 	menubar.Append(keysMenu)
+	menubar.Append(optionsMenu)
 	ctx.fixed.Add(menubar)
 }
 